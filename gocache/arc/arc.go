@@ -34,13 +34,31 @@ type ARCCache struct {
 	t2 simplelfu.LFUCache // T2 is the LFU for frequently accessed items
 	b2 simplelfu.LFUCache // B2 is the LFU for evictions from t2
 
+	// onEvict 在条目离开T1/T2时被调用，moving 为true表示条目正从T1晋升到T2，此时不算淘汰
+	onEvict func(key interface{}, value interface{}, expirationTime int64)
+	moving  bool
+
 	lock sync.RWMutex
 }
 
 // NewARC creates an ARC of the given size
 func NewARC(size int) (*ARCCache, error) {
+	return NewARCWithEvict(size, nil)
+}
+
+// NewARCWithEvict constructs a fixed size ARC with the given eviction
+// callback. The callback fires whenever an entry leaves the cache, but not
+// when it is promoted from T1 to T2.
+// NewARCWithEvict 用于在缓存条目被淘汰时的回调函数
+func NewARCWithEvict(size int, onEvicted func(key interface{}, value interface{}, expirationTime int64)) (*ARCCache, error) {
+	c := &ARCCache{
+		size:    size,
+		p:       0,
+		onEvict: onEvicted,
+	}
+
 	// Create the sub LRUs
-	t1, err := simplelru.NewLRU(size, nil)
+	t1, err := simplelru.NewLRU(size, c.evicted)
 	if err != nil {
 		return nil, err
 	}
@@ -48,7 +66,7 @@ func NewARC(size int) (*ARCCache, error) {
 	if err != nil {
 		return nil, err
 	}
-	t2, err := simplelfu.NewLFU(size, nil)
+	t2, err := simplelfu.NewLFU(size, c.evicted)
 	if err != nil {
 		return nil, err
 	}
@@ -58,17 +76,26 @@ func NewARC(size int) (*ARCCache, error) {
 	}
 
 	// Initialize the ARC
-	c := &ARCCache{
-		size: size,
-		p:    0,
-		t1:   t1,
-		b1:   b1,
-		t2:   t2,
-		b2:   b2,
-	}
+	c.t1, c.b1 = t1, b1
+	c.t2, c.b2 = t2, b2
 	return c, nil
 }
 
+// evicted 转发T1/T2的淘汰回调，晋升过程中的移除会被忽略
+func (c *ARCCache) evicted(key interface{}, value interface{}, expirationTime int64) {
+	if c.onEvict != nil && !c.moving {
+		c.onEvict(key, value, expirationTime)
+	}
+}
+
+// promote 将key从T1移到T2
+func (c *ARCCache) promote(key, value interface{}, expirationTime int64) {
+	c.moving = true
+	c.t1.Remove(key)
+	c.moving = false
+	c.t2.Add(key, value, expirationTime)
+}
+
 // Get looks up a key's value from the cache.
 // Get 从缓存中查找一个键的值。
 func (c *ARCCache) Get(key interface{}) (value interface{}, expirationTime int64, ok bool) {
@@ -78,8 +105,7 @@ func (c *ARCCache) Get(key interface{}) (value interface{}, expirationTime int64
 	// If the value is contained in T1 (recent), then
 	// promote it to T2 (frequent)
 	if val, expirationTime, ok := c.t1.Peek(key); ok {
-		c.t1.Remove(key)
-		c.t2.Add(key, val, expirationTime)
+		c.promote(key, val, expirationTime)
 		return val, expirationTime, ok
 	}
 
@@ -101,8 +127,7 @@ func (c *ARCCache) Add(key, value interface{}, expirationTime int64) {
 	// Check if the value is contained in T1 (recent), and potentially
 	// promote it to frequent T2
 	if c.t1.Contains(key) {
-		c.t1.Remove(key)
-		c.t2.Add(key, value, expirationTime)
+		c.promote(key, value, expirationTime)
 		return
 	}
 
@@ -191,19 +216,47 @@ func (c *ARCCache) Add(key, value interface{}, expirationTime int64) {
 // replace is used to adaptively evict from either T1 or T2
 // based on the current learned value of P
 // replace 用于自适应地从T1或T2中驱逐,根据P的当前学习值
-func (c *ARCCache) replace(b2ContainsKey bool) {
+func (c *ARCCache) replace(b2ContainsKey bool) (key interface{}, value interface{}, expirationTime int64, ok bool) {
 	t1Len := c.t1.Len()
 	if t1Len > 0 && (t1Len > c.p || (t1Len == c.p && b2ContainsKey)) {
-		k, _, expirationTime, ok := c.t1.RemoveOldest()
-		if ok {
-			c.b1.Add(k, nil, expirationTime)
-		}
-	} else {
-		k, _, expirationTime, ok := c.t2.RemoveOldest()
-		if ok {
-			c.b2.Add(k, nil, expirationTime)
-		}
+		return c.evictT1()
+	}
+	return c.evictT2()
+}
+
+// evictT1 淘汰T1中最老的项并记录到幽灵列表B1
+func (c *ARCCache) evictT1() (key interface{}, value interface{}, expirationTime int64, ok bool) {
+	key, value, expirationTime, ok = c.t1.RemoveOldest()
+	if ok {
+		c.b1.Add(key, nil, expirationTime)
+	}
+	return
+}
+
+// evictT2 淘汰T2中最不常用的项并记录到幽灵列表B2
+func (c *ARCCache) evictT2() (key interface{}, value interface{}, expirationTime int64, ok bool) {
+	key, value, expirationTime, ok = c.t2.RemoveOldest()
+	if ok {
+		c.b2.Add(key, nil, expirationTime)
+	}
+	return
+}
+
+// RemoveOldest evicts one entry from T1 or T2 based on the current
+// learned value of P, as if the cache had run out of room.
+// RemoveOldest 按照P的当前学习值淘汰一个条目，供调用方按自定义容量(如字节数)驱逐
+func (c *ARCCache) RemoveOldest() (key interface{}, value interface{}, expirationTime int64, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if key, value, expirationTime, ok = c.replace(false); ok {
+		return
+	}
+	// replace 选中的列表为空，从另一个列表淘汰
+	if key, value, expirationTime, ok = c.evictT1(); ok {
+		return
 	}
+	return c.evictT2()
 }
 
 // Len returns the number of cached entries
@@ -403,4 +403,41 @@ func TestARC_Peek(t *testing.T) {
 	if l.Contains(1) {
 		t.Errorf("should not have updated recent-ness of 1")
 	}
-}
\ No newline at end of file
+}
+
+// Test that the evict callback ignores promotions and RemoveOldest evicts
+func TestARC_EvictCallback(t *testing.T) {
+	evicted := make(map[interface{}]int)
+	l, err := NewARCWithEvict(4, func(k interface{}, v interface{}, expirationTime int64) {
+		evicted[k]++
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	l.Add(2, 2, 0)
+	l.Get(1) // 1 从 T1 晋升到 T2
+	if len(evicted) != 0 {
+		t.Fatalf("promotion should not be reported as eviction: %v", evicted)
+	}
+
+	if k, _, _, ok := l.RemoveOldest(); !ok || k != 2 {
+		t.Fatalf("RemoveOldest should evict 2 from T1, got %v %v", k, ok)
+	}
+	if k, _, _, ok := l.RemoveOldest(); !ok || k != 1 {
+		t.Fatalf("RemoveOldest should fall back to T2 and evict 1, got %v %v", k, ok)
+	}
+	if _, _, _, ok := l.RemoveOldest(); ok {
+		t.Fatalf("RemoveOldest on empty cache should fail")
+	}
+	if evicted[1] != 1 || evicted[2] != 1 {
+		t.Fatalf("bad evictions: %v", evicted)
+	}
+
+	l.Add(3, 3, 0)
+	l.Remove(3)
+	if evicted[3] != 1 {
+		t.Fatalf("Remove should be reported as eviction: %v", evicted)
+	}
+}
@@ -0,0 +1,196 @@
+package gocache
+
+import (
+	"fmt"
+	"gocache/arc"
+	"gocache/highperformance"
+	"gocache/lru"
+	"time"
+)
+
+// backend 模块把各淘汰算法适配成 cache 使用的统一接口
+// 并发控制由 cache.mu 负责，因此 backend 的实现不需要自己加锁
+
+type backend interface {
+	add(key string, value ByteView)
+	get(key string) (ByteView, bool)
+	remove(key string)
+	stats() CacheStats // 只需填写 Bytes、Items、Evictions 与 Expirations
+}
+
+// newBackend 根据 cacheType 创建对应的淘汰算法
+// capacity 为最大字节数，maxEntries 为最大条目数(TYPE_SIMPLE 只按字节限制)
+func newBackend(cacheType string, capacity int64, maxEntries int) (backend, error) {
+	if cacheType == "" || cacheType == TYPE_SIMPLE {
+		return &simpleBackend{lru: lru.New(capacity, nil)}, nil
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+
+	b := &kvBackend{capacity: capacity}
+	var err error
+	switch cacheType {
+	case TYPE_LRU:
+		b.store, err = highperformance.NewLruWithEvict(maxEntries, b.onEvicted)
+	case TYPE_LFU:
+		b.store, err = highperformance.NewLfuWithEvict(maxEntries, b.onEvicted)
+	case TYPE_HASHLRU:
+		b.store, err = highperformance.NewHashLruWithEvict(maxEntries, 0, b.onEvicted)
+	case TYPE_HASHLFU:
+		b.store, err = highperformance.NewHashLfuWithEvict(maxEntries, 0, b.onEvicted)
+	case TYPE_ARC:
+		var c *arc.ARCCache
+		c, err = arc.NewARCWithEvict(maxEntries, b.onEvicted)
+		b.store = arcStore{c}
+	default:
+		return nil, validCacheType(cacheType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s cache failed: %v", cacheType, err)
+	}
+	return b, nil
+}
+
+// simpleBackend 使用 lru.Cache，它自己按字节数淘汰并处理过期
+type simpleBackend struct {
+	lru *lru.Cache
+}
+
+func (b *simpleBackend) add(key string, value ByteView) {
+	var ttl time.Duration
+	if !value.expire.IsZero() {
+		// lru.Cache 以 0 代表永不过期，已过期的值至少给 1ns
+		if ttl = time.Until(value.expire); ttl <= 0 {
+			ttl = time.Nanosecond
+		}
+	}
+	b.lru.Add(key, value, ttl)
+}
+
+func (b *simpleBackend) get(key string) (ByteView, bool) {
+	if v, ok := b.lru.Get(key); ok {
+		return v.(ByteView), true
+	}
+	return ByteView{}, false
+}
+
+func (b *simpleBackend) remove(key string) {
+	b.lru.Remove(key)
+}
+
+func (b *simpleBackend) stats() CacheStats {
+	s := b.lru.Stats()
+	return CacheStats{
+		Bytes:       b.lru.Bytes(),
+		Items:       int64(b.lru.Len()),
+		Evictions:   s.Evictions,
+		Expirations: s.Expirations,
+	}
+}
+
+// kvStore 是 highperformance 与 arc 中各缓存共有的方法集
+// 它们只按条目数淘汰，过期时间以毫秒时间戳表示
+type kvStore interface {
+	Add(key, value interface{}, expirationTime int64) bool
+	Get(key interface{}) (value interface{}, expirationTime int64, ok bool)
+	Peek(key interface{}) (value interface{}, expirationTime int64, ok bool)
+	Remove(key interface{}) bool
+	RemoveOldest() (key interface{}, value interface{}, expirationTime int64, ok bool)
+	Len() int
+}
+
+// kvBackend 在 kvStore 之上补充按字节数淘汰的能力
+// 条目离开 store 时(淘汰、过期、删除)都会回调 onEvicted，以此维护已用字节数
+// arc 不提供淘汰统计，因此由 kvBackend 在 onEvicted 中按原因统一计数
+type kvBackend struct {
+	store    kvStore
+	capacity int64 // 允许使用的最大字节数，0 代表无限制
+	nbytes   int64 // 当前已使用的字节数
+	removing bool  // 正在执行 remove，此时离开 store 的条目不计入淘汰
+
+	evictions, expirations int64
+}
+
+func (b *kvBackend) onEvicted(key interface{}, value interface{}, expirationTime int64) {
+	b.nbytes -= int64(len(key.(string))) + int64(value.(ByteView).Len())
+	switch {
+	case expirationTime != 0 && expirationTime <= toMillis(time.Now()):
+		b.expirations++
+	case !b.removing:
+		b.evictions++
+	}
+}
+
+func (b *kvBackend) add(key string, value ByteView) {
+	size := int64(len(key)) + int64(value.Len())
+	delta := size
+	// Peek 会顺带清理已过期的旧值，此时按新增处理
+	if old, _, ok := b.store.Peek(key); ok {
+		delta = int64(value.Len()) - int64(old.(ByteView).Len())
+	}
+	// 先腾出空间再写入，与各算法按条目数淘汰时的顺序保持一致
+	for b.capacity != 0 && b.capacity < b.nbytes+delta {
+		k, _, _, ok := b.store.RemoveOldest()
+		if !ok {
+			break
+		}
+		if k == key {
+			delta = size
+		}
+	}
+	b.nbytes += delta
+	b.store.Add(key, value, toMillis(value.expire))
+	// 单个条目就超过容量时，它自己也会被淘汰
+	for b.capacity != 0 && b.capacity < b.nbytes {
+		if _, _, _, ok := b.store.RemoveOldest(); !ok {
+			break
+		}
+	}
+}
+
+func (b *kvBackend) get(key string) (ByteView, bool) {
+	if v, _, ok := b.store.Get(key); ok {
+		return v.(ByteView), true
+	}
+	return ByteView{}, false
+}
+
+func (b *kvBackend) remove(key string) {
+	b.removing = true
+	b.store.Remove(key)
+	b.removing = false
+}
+
+func (b *kvBackend) stats() CacheStats {
+	return CacheStats{
+		Bytes:       b.nbytes,
+		Items:       int64(b.store.Len()),
+		Evictions:   b.evictions,
+		Expirations: b.expirations,
+	}
+}
+
+// toMillis 把过期时间转换成 kvStore 使用的毫秒时间戳，零值代表永不过期
+func toMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / 1e6
+}
+
+// arcStore 让 arc.ARCCache 满足 kvStore
+type arcStore struct {
+	*arc.ARCCache
+}
+
+func (s arcStore) Add(key, value interface{}, expirationTime int64) bool {
+	s.ARCCache.Add(key, value, expirationTime)
+	return true
+}
+
+func (s arcStore) Remove(key interface{}) bool {
+	present := s.ARCCache.Contains(key)
+	s.ARCCache.Remove(key)
+	return present
+}
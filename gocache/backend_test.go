@@ -0,0 +1,88 @@
+package gocache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+var allCacheTypes = []string{TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_HASHLRU, TYPE_HASHLFU}
+
+// 测试各淘汰算法的基本读写与过期
+func TestBackendAddGetExpire(t *testing.T) {
+	for _, typ := range allCacheTypes {
+		c := &cache{cacheType: typ}
+		c.add("k1", ByteView{b: []byte("v1")}, 0)
+		c.add("k2", ByteView{b: []byte("v2")}, 50*time.Millisecond)
+
+		if v, ok := c.get("k1"); !ok || v.String() != "v1" {
+			t.Fatalf("[%s] k1 should be cached, got %q %v", typ, v.String(), ok)
+		}
+		if v, ok := c.get("k2"); !ok || v.String() != "v2" {
+			t.Fatalf("[%s] k2 should be cached, got %q %v", typ, v.String(), ok)
+		}
+		time.Sleep(60 * time.Millisecond)
+		if _, ok := c.get("k2"); ok {
+			t.Fatalf("[%s] k2 should be expired", typ)
+		}
+		if _, ok := c.get("k1"); !ok {
+			t.Fatalf("[%s] k1 should never expire", typ)
+		}
+	}
+}
+
+// 测试各淘汰算法都遵守字节数限制
+func TestBackendCapacity(t *testing.T) {
+	for _, typ := range allCacheTypes {
+		// 每个条目占 len("key0")+len("value0") = 10 字节
+		c := &cache{cacheType: typ, capacity: 30}
+		for i := 0; i < 10; i++ {
+			c.add(fmt.Sprintf("key%d", i), ByteView{b: []byte(fmt.Sprintf("value%d", i))}, 0)
+		}
+		// 覆写已有的 key 不应重复计算 key 的长度
+		c.add("key9", ByteView{b: []byte("value9")}, 0)
+
+		n := 0
+		for i := 0; i < 10; i++ {
+			if _, ok := c.get(fmt.Sprintf("key%d", i)); ok {
+				n++
+			}
+		}
+		if n == 0 || n > 3 {
+			t.Fatalf("[%s] expected 1~3 entries within 30 bytes, got %d", typ, n)
+		}
+		if _, ok := c.get("key9"); !ok {
+			t.Fatalf("[%s] the newest entry should survive", typ)
+		}
+		if kv, ok := c.backend.(*kvBackend); ok && kv.nbytes > 30 {
+			t.Fatalf("[%s] used %d bytes, capacity is 30", typ, kv.nbytes)
+		}
+	}
+}
+
+// 测试 Group 能够通过选项选择淘汰算法
+func TestGroupWithCacheType(t *testing.T) {
+	for _, typ := range allCacheTypes {
+		loads := 0
+		g := NewGroup("cacheType_"+typ, 1<<10, time.Minute, GetterFunc(func(key string) ([]byte, error) {
+			loads++
+			return []byte(key), nil
+		}), WithCacheType(typ), WithMaxEntries(16))
+
+		for i := 0; i < 2; i++ {
+			if v, err := g.Get("Tom"); err != nil || v.String() != "Tom" {
+				t.Fatalf("[%s] unexpected value %q, err %v", typ, v.String(), err)
+			}
+		}
+		if loads != 1 {
+			t.Fatalf("[%s] expected 1 load, got %d", typ, loads)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("NewGroup should panic on unknown cache type")
+		}
+	}()
+	NewGroup("cacheType_bad", 1<<10, time.Minute, GetterFunc(mockGetter), WithCacheType("fifo"))
+}
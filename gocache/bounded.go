@@ -0,0 +1,78 @@
+package gocache
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// bounded模块实现有界负载模式下的节点选择
+// 热点 key 的所属节点进行中的请求过多时，Pick 顺时针选择下一个负载未达上限的节点，
+// 收到转发请求的节点只在本地查找或加载，不会再转发回所属节点
+
+// redirectHeader 标记请求是因为所属节点负载过高或不可用而发往其他节点的
+const redirectHeader = "gocache-redirect"
+
+// redirectedKey 是 ctx 中标记请求只能在本地处理的 key
+type redirectedKey struct{}
+
+// boundedPeer 包装 Pick 选出的 client，在请求进行期间计入节点的负载
+// 它是可比较的值类型，GetMulti 仍能按节点合并同一节点的 key
+type boundedPeer struct {
+	c        *client
+	s        *server
+	addr     string
+	redirect bool // 选出的节点不是 key 的所属节点
+}
+
+func (p boundedPeer) Fetch(group string, key string) ([]byte, error) {
+	v, err := p.FetchContext(context.Background(), group, key)
+	if err != nil {
+		return nil, err
+	}
+	return v.b, nil
+}
+
+func (p boundedPeer) FetchContext(ctx context.Context, group string, key string) (ByteView, error) {
+	defer p.s.track(p.addr)()
+	return p.c.FetchContext(p.outgoing(ctx), group, key)
+}
+
+func (p boundedPeer) FetchMulti(ctx context.Context, group string, keys []string) (map[string]ByteView, map[string]error, error) {
+	defer p.s.track(p.addr)()
+	return p.c.FetchMulti(p.outgoing(ctx), group, keys)
+}
+
+// String 返回远端节点的服务名称
+func (p boundedPeer) String() string {
+	if p.redirect {
+		return fmt.Sprintf("%s (redirected)", p.c.String())
+	}
+	return p.c.String()
+}
+
+// outgoing 为转发的请求带上 redirectHeader
+func (p boundedPeer) outgoing(ctx context.Context) context.Context {
+	if !p.redirect {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, redirectHeader, "1")
+}
+
+// withRedirected 在请求带有 redirectHeader 时标记 ctx，Group 据此不再选择远端节点
+func withRedirected(ctx context.Context) context.Context {
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(redirectHeader)) > 0 {
+		return context.WithValue(ctx, redirectedKey{}, true)
+	}
+	return ctx
+}
+
+// redirected 返回 ctx 对应的请求是否是其他节点因负载转发来的
+func redirected(ctx context.Context) bool {
+	v, _ := ctx.Value(redirectedKey{}).(bool)
+	return v
+}
+
+var _ ContextFetcher = boundedPeer{}
+var _ BatchFetcher = boundedPeer{}
@@ -14,12 +14,20 @@ import "time"
 // 可以被恶意修改。因此需要将slice封装成只读的ByteView
 
 type ByteView struct {
-	b      []byte
-	s      string
-	expire time.Time
+	b        []byte
+	s        string
+	expire   time.Time
+	notFound bool      // 墓碑值，记录数据源中不存在该 key
+	refresh  time.Time // 超过该时刻后命中会触发后台刷新，零值代表不刷新
 }
 
 
+// NewByteView 创建一个 ByteView，expire 为零值代表永不过期
+// 供自定义 Fetcher 的实现方返回带过期时间的远程值
+func NewByteView(b []byte, expire time.Time) ByteView {
+	return ByteView{b: cloneBytes(b), expire: expire}
+}
+
 func cloneBytes(b []byte) []byte {
 	c := make([]byte, len(b))
 	copy(c, b)
@@ -42,4 +50,25 @@ func (v ByteView) ByteSlice() []byte {
 
 func (v ByteView) String() string {
 	return string(v.b)
-}
\ No newline at end of file
+}
+
+// Expire 返回缓存值的过期时间，零值代表永不过期
+func (v ByteView) Expire() time.Time {
+	return v.expire
+}
+
+// toUnixNano 与 fromUnixNano 在过期时间与 gRPC 消息中的 Unix 纳秒之间转换，0 代表永不过期
+// 节点之间按绝对时刻传递过期时间，因此依赖各节点的时钟基本同步
+func toUnixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
@@ -2,6 +2,7 @@ package gocache
 
 import (
 	"testing"
+	"time"
 )
 
 func TestByteView_Len(t *testing.T) {
@@ -32,10 +33,13 @@ func TestByteView_String(t *testing.T) {
 	}
 }
 
-// func TestByteView_Expire(t *testing.T) {
-// 	now := time.Now()
-// 	v := ByteView{e: now}
-// 	if !v.Expire().Equal(now) {
-// 		t.Errorf("ByteView.Expire() = %v, want %v", v.Expire(), now)
-// 	}
-// }
\ No newline at end of file
+func TestByteView_Expire(t *testing.T) {
+	now := time.Now()
+	v := NewByteView([]byte("hello"), now)
+	if !v.Expire().Equal(now) {
+		t.Errorf("ByteView.Expire() = %v, want %v", v.Expire(), now)
+	}
+	if !(ByteView{}).Expire().IsZero() {
+		t.Errorf("zero ByteView should never expire")
+	}
+}
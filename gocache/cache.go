@@ -1,56 +1,108 @@
 package gocache
 
 import (
-	// "gocache/highperformance"
-	"gocache/lru"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
+
 // CacheType constants to define the type of cache
 const (
-    TYPE_SIMPLE = "lru_simple"
-    TYPE_LRU    = "lru"
-    TYPE_LFU    = "lfu"
-    TYPE_ARC    = "arc"
+	TYPE_SIMPLE  = "lru_simple"
+	TYPE_LRU     = "lru"
+	TYPE_LFU     = "lfu"
+	TYPE_ARC     = "arc"
+	TYPE_HASHLRU = "hashlru"
+	TYPE_HASHLFU = "hashlfu"
 )
 
-const defaultExpiration = 1 * time.Minute
+const (
+	defaultExpiration = 1 * time.Minute
+	// 数据源中不存在的 key 默认缓存的时间，应明显短于正常值的过期时间
+	defaultNegativeTTL = 10 * time.Second
+	// 除 TYPE_SIMPLE 外的淘汰算法都按条目数建立，字节数限制在此基础上额外生效
+	defaultMaxEntries = 1 << 16
+)
 
 type cache struct {
-	mu       sync.Mutex
-	lru      *lru.Cache
-	capacity int64
+	mu         sync.Mutex
+	backend    backend
+	nget, nhit int64  // 查找次数与命中次数，原子操作维护
+	cacheType  string // 淘汰算法，为空时等价于 TYPE_SIMPLE
+	capacity   int64  // 允许使用的最大字节数，0 代表无限制
+	maxEntries int    // 允许存放的最大条目数，对 TYPE_SIMPLE 无效
 }
 
 func newCache(capacity int64) *cache {
-    return &cache{capacity: capacity}
+	return &cache{capacity: capacity}
 }
 
-// 在 add 方法中，判断了 c.lru 是否为 nil，如果等于 nil 再创建实例。这种方法称之为延迟初始化(Lazy Initialization)，
+// 在 add 方法中，判断了 c.backend 是否为 nil，如果等于 nil 再创建实例。这种方法称之为延迟初始化(Lazy Initialization)，
 // 一个对象的延迟初始化意味着该对象的创建将会延迟至第一次使用该对象时。主要用于提高性能，并减少程序内存要求。
-func (c *cache) add(key string, value ByteView,expiration ...time.Duration) {
+func (c *cache) add(key string, value ByteView, expiration ...time.Duration) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.lru == nil {
-		c.lru = lru.New(c.capacity, nil)
+	if c.backend == nil {
+		b, err := newBackend(c.cacheType, c.capacity, c.maxEntries)
+		if err != nil {
+			panic(err)
+		}
+		c.backend = b
 	}
 	var exp time.Duration
 	if len(expiration) > 0 {
-        exp = expiration[0]
-    } else {
-        exp = defaultExpiration
-    }
-	c.lru.Add(key, value, exp)
+		exp = expiration[0]
+	} else {
+		exp = defaultExpiration
+	}
+	// 过期时间随值一起保存，exp 为 0 代表永不过期；value 已带有过期时间时以它为准
+	if exp > 0 && value.expire.IsZero() {
+		value.expire = time.Now().Add(exp)
+	}
+	c.backend.add(key, value)
 }
 
 func (c *cache) get(key string) (value ByteView, ok bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.lru == nil {
+	atomic.AddInt64(&c.nget, 1)
+	if c.backend == nil {
 		return ByteView{}, false
 	}
-	if v, ok := c.lru.Get(key); ok {
-		return v.(ByteView), ok
+	value, ok = c.backend.get(key)
+	if ok {
+		atomic.AddInt64(&c.nhit, 1)
+	}
+	return value, ok
+}
+
+func (c *cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.backend == nil {
+		return
+	}
+	c.backend.remove(key)
+}
+
+// stats 返回缓存当前的统计数据
+func (c *cache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var s CacheStats
+	if c.backend != nil {
+		s = c.backend.stats()
+	}
+	s.Gets, s.Hits = atomic.LoadInt64(&c.nget), atomic.LoadInt64(&c.nhit)
+	return s
+}
+
+// validCacheType 判断 cacheType 是否为已支持的淘汰算法
+func validCacheType(cacheType string) error {
+	switch cacheType {
+	case "", TYPE_SIMPLE, TYPE_LRU, TYPE_LFU, TYPE_ARC, TYPE_HASHLRU, TYPE_HASHLFU:
+		return nil
 	}
-	return ByteView{}, false
+	return fmt.Errorf("unknown cache type %q", cacheType)
 }
@@ -2,88 +2,310 @@ package gocache
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	pb "gocache/gocachepb"
 	"gocache/registry"
 
-	clientv3 "go.etcd.io/etcd/client/v3"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
-func printConnState(conn *grpc.ClientConn) {
-	val := reflect.ValueOf(conn).Elem()
-	state := val.FieldByName("state")
-	log.Println("Connection state:", state)
-}
-
 // client 模块实现gocache访问其他远程节点 从而获取缓存的能力
 type client struct {
-	name       string // 服务名称 pcache/ip:addr
-	etcdClient *clientv3.Client
-	conn       *grpc.ClientConn
-	mu         sync.Mutex
+	name   string // 服务名称 gocache/ip:addr，直连时为 ip:addr
+	conn   *grpc.ClientConn
+	closed bool // Close 之后不再建立连接，避免已移出哈希环的 client 重新占用连接池中的连接
+	mu     sync.Mutex
+	log    Logger // 为 nil 时使用 slog.Default()
+
+	registry    registry.Registry // 解析远端节点，direct 为 true 时不使用
+	direct      bool              // 直接连接 name 对应的地址，不经过 Registry 解析
+	pool        *connPool         // 与访问同一节点的其他 client 共用连接
+	dialOptions []grpc.DialOption // 追加到默认 DialOption 之后
+	timeout     time.Duration     // ctx 没有 deadline 时的超时时间
+	meta        atomic.Value      // 远端节点注册的 registry.Metadata，没有时视为支持所有功能
 }
 
-func (c *client) initialize() error {
+// 通过 NewClient 与 NewDirectClient 创建的 client 共用的 etcd Registry 与连接池
+// 所有 client 共用一个 etcd 客户端，它在第一次使用时创建
+var (
+	defaultRegistry = registry.NewEtcdRegistry(registry.Config{Etcd: defaultEtcdConfig})
+	defaultPool     = newConnPool()
+)
+
+// initialize 返回持有锁时检查或建立的连接，调用方使用返回值而不是再次读取 c.conn，
+// 因为并发的 Close 可能随时将 c.conn 置为 nil
+func (c *client) initialize() (*grpc.ClientConn, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if c.etcdClient == nil {
-		var err error
-		c.etcdClient, err = clientv3.New(defaultEtcdConfig)
-		if err != nil {
-			return fmt.Errorf("failed to create etcd client: %v", err)
-		}
+	if c.conn != nil {
+		return c.conn, nil
 	}
-
-	if c.conn == nil {
-		conn, err := registry.EtcdDial(c.etcdClient, c.name)
-		if err != nil {
-			return fmt.Errorf("failed to dial gRPC server: %v", err)
-		}
-		c.conn = conn
+	if c.closed {
+		return nil, fmt.Errorf("client of peer %s is closed", c.name)
+	}
+	conn, err := c.pool.acquire(c.name, c.dial)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial gRPC server: %v", err)
 	}
+	c.conn = conn
+	return conn, nil
+}
 
-	return nil
+// dial 建立到远端节点的连接：直连模式下直接连接地址，否则通过 Registry 解析
+func (c *client) dial() (*grpc.ClientConn, error) {
+	opts := append([]grpc.DialOption{grpc.WithChainUnaryInterceptor(peerInterceptor(c.name), tracingClientInterceptor())}, c.dialOptions...)
+	if c.direct {
+		// passthrough 不做任何解析，连接不依赖 etcd
+		opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+		return grpc.Dial("passthrough:///"+c.name, opts...)
+	}
+	return registry.Dial(c.registry, c.name, opts...)
 }
 
 // 使用实现了 PeerGetter 接口的 httpGetter 从访问远程节点，获取缓存值。 getFromPeer 从remote peer获取对应缓存值
 func (c *client) Fetch(group string, key string) ([]byte, error) {
-
-	if err := c.initialize(); err != nil {
-		log.Printf("Initialization failed: %v", err)
+	v, err := c.FetchContext(context.Background(), group, key)
+	if err != nil {
 		return nil, err
 	}
-	log.Println("Initialization successful")
+	return v.b, nil
+}
+
+// FetchContext 在 ctx 的 deadline 内从远程节点获取缓存值，ctx 没有 deadline 时使用 rpcTimeout，默认 10 秒超时
+// deadline 会随 gRPC 请求传递到远程节点
+// 返回值带有远程节点上该值的过期时刻，本地副本与它同时过期
+func (c *client) FetchContext(ctx context.Context, group string, key string) (ByteView, error) {
 	//如果连接成功，会使用这个连接创建一个新的gRPC客户端
-	grpcClient := pb.NewGroupCacheClient(c.conn)
-	if grpcClient == nil {
-		log.Println("Failed to create gRPC client")
-		return nil, fmt.Errorf("failed to create gRPC client")
+	grpcClient, err := c.grpcClient()
+	if err != nil {
+		return ByteView{}, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.rpcTimeout())
+		defer cancel()
+	}
 	//发送一个gPRC请求到远程服务，请求包括组名和键名，
 	resp, err := grpcClient.Get(ctx, &pb.Request{Group: group, Key: key})
+	if status.Code(err) == codes.NotFound {
+		return ByteView{}, &NotFoundError{Group: group, Key: key}
+	}
+	if err != nil {
+		c.logger().Debug("gRPC call failed", "service", c.name, "err", err)
+		return ByteView{}, fmt.Errorf("could not get %s/%s from peer %s: %v", group, key, c.name, err)
+	}
+	c.logger().Debug("Successfully sent gRPC request", "service", c.name)
+	return ByteView{b: resp.GetValue(), expire: fromUnixNano(resp.GetExpire())}, nil
+}
+
+// FetchMulti 通过一次 gRPC 请求从远程节点获取多个缓存值
+// 远端节点没有公布 FeatureGetMulti 时逐个获取
+func (c *client) FetchMulti(ctx context.Context, group string, keys []string) (map[string]ByteView, map[string]error, error) {
+	if !c.supports(FeatureGetMulti) {
+		values, keyErrs := make(map[string]ByteView, len(keys)), make(map[string]error)
+		for _, key := range keys {
+			v, err := c.FetchContext(ctx, group, key)
+			if err != nil {
+				keyErrs[key] = err
+				continue
+			}
+			values[key] = v
+		}
+		return values, keyErrs, nil
+	}
+	grpcClient, err := c.grpcClient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.rpcTimeout())
+		defer cancel()
+	}
+	resp, err := grpcClient.GetMulti(ctx, &pb.MultiRequest{Group: group, Keys: keys})
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get %d keys of %s from peer %s: %v", len(keys), group, c.name, err)
+	}
+	values, keyErrs := make(map[string]ByteView, len(keys)), make(map[string]error)
+	for _, kv := range resp.GetValues() {
+		if kv.GetNotFound() {
+			keyErrs[kv.GetKey()] = &NotFoundError{Group: group, Key: kv.GetKey()}
+			continue
+		}
+		if kv.GetError() != "" {
+			keyErrs[kv.GetKey()] = errors.New(kv.GetError())
+			continue
+		}
+		values[kv.GetKey()] = ByteView{b: kv.GetValue(), expire: fromUnixNano(kv.GetExpire())}
+	}
+	return values, keyErrs, nil
+}
+
+// Set 将缓存值写入远程节点
+func (c *client) Set(group string, key string, value []byte, ttl time.Duration) error {
+	if !c.supports(FeatureUpdate) {
+		return c.unsupported(FeatureUpdate)
+	}
+	grpcClient, err := c.grpcClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.rpcTimeout())
+	defer cancel()
+	_, err = grpcClient.Set(ctx, &pb.SetRequest{Group: group, Key: key, Value: value, Ttl: int64(ttl)})
+	if err != nil {
+		return fmt.Errorf("could not set %s/%s on peer %s: %v", group, key, c.name, err)
+	}
+	return nil
+}
+
+// Remove 删除远程节点上的缓存值
+func (c *client) Remove(group string, key string) error {
+	if !c.supports(FeatureUpdate) {
+		return c.unsupported(FeatureUpdate)
+	}
+	grpcClient, err := c.grpcClient()
 	if err != nil {
-		log.Printf("gRPC call failed: %v", err)
-		return nil, fmt.Errorf("could not get %s/%s from peer %s: %v", group, key, c.name, err)
+		return err
 	}
-	log.Println("Successfully sent gRPC request")
-	return resp.GetValue(), nil
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.rpcTimeout())
+	defer cancel()
+	if _, err = grpcClient.Remove(ctx, &pb.Request{Group: group, Key: key}); err != nil {
+		return fmt.Errorf("could not remove %s/%s on peer %s: %v", group, key, c.name, err)
+	}
+	return nil
+}
+
+// Invalidate 通知远程节点丢弃其持有的缓存值
+func (c *client) Invalidate(group string, key string) error {
+	if !c.supports(FeatureUpdate) {
+		return c.unsupported(FeatureUpdate)
+	}
+	grpcClient, err := c.grpcClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.rpcTimeout())
+	defer cancel()
+	if _, err = grpcClient.Invalidate(ctx, &pb.Request{Group: group, Key: key}); err != nil {
+		return fmt.Errorf("could not invalidate %s/%s on peer %s: %v", group, key, c.name, err)
+	}
+	return nil
+}
+
+// Stats 获取远程节点上 Group 的统计数据
+func (c *client) Stats(ctx context.Context, group string) (Stats, error) {
+	if !c.supports(FeatureStats) {
+		return Stats{}, c.unsupported(FeatureStats)
+	}
+	grpcClient, err := c.grpcClient()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.rpcTimeout())
+		defer cancel()
+	}
+	resp, err := grpcClient.Stats(ctx, &pb.StatsRequest{Group: group})
+	if err != nil {
+		return Stats{}, fmt.Errorf("could not get stats of %s from peer %s: %v", group, c.name, err)
+	}
+	return fromPBStats(resp), nil
+}
+
+// grpcClient 确保连接已建立，并基于该连接创建gRPC客户端
+func (c *client) grpcClient() (pb.GroupCacheClient, error) {
+	conn, err := c.initialize()
+	if err != nil {
+		c.logger().Error("Initialization failed", "service", c.name, "err", err)
+		return nil, err
+	}
+	c.logger().Debug("Initialization successful", "service", c.name)
+	return pb.NewGroupCacheClient(conn), nil
+}
+
+// Close 释放与远端节点的连接，没有其他 client 使用时连接被关闭，之后的请求返回错误
+// 进行中的请求使用的连接被关闭时同样返回错误
+func (c *client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	c.conn = nil
+	return c.pool.release(c.name)
+}
+
+// setMetadata 记录远端节点注册的元数据
+func (c *client) setMetadata(md registry.Metadata) {
+	c.meta.Store(md)
+}
+
+// supports 返回远端节点是否支持 feature
+// 不知道节点的元数据，或节点没有公布功能列表(早于元数据的版本)时视为支持
+func (c *client) supports(feature string) bool {
+	md, ok := c.meta.Load().(registry.Metadata)
+	if !ok || md.Features == nil {
+		return true
+	}
+	return md.HasFeature(feature)
+}
+
+// unsupported 返回远端节点不支持 feature 的错误
+func (c *client) unsupported(feature string) error {
+	return fmt.Errorf("peer %s does not support %s", c.name, feature)
+}
+
+// rpcTimeout 返回 ctx 没有 deadline 时访问远端节点的超时时间
+func (c *client) rpcTimeout() time.Duration {
+	if c.timeout <= 0 {
+		return defaultRPCTimeout
+	}
+	return c.timeout
+}
+
+// logger 返回 client 使用的 Logger
+func (c *client) logger() Logger {
+	return loggerOrDefault(c.log)
+}
+
+// String 返回远端节点的服务名称
+func (c *client) String() string {
+	return c.name
 }
 
 // 用于创建新的client实例，接收一个服务名作为参数，这个服务名是etcd中注册的服务名，用于在 Fetch 方法中与远程服务通信。
+// service 形如 gocache/ip:port 时只访问注册在 gocache 下的该节点
 func NewClient(service string) *client {
-	return &client{name: service}
+	return &client{name: service, registry: defaultRegistry, pool: defaultPool, timeout: defaultRPCTimeout}
+}
+
+// NewDirectClient 创建直接连接 addr 的 client，不需要 etcd
+func NewDirectClient(addr string) *client {
+	return &client{name: addr, direct: true, pool: defaultPool, timeout: defaultRPCTimeout}
 }
 
 // 测试Client是否实现了Fetcher接口，验证 client 类型是否实现了 Fetcher 接口。
 // 这是 Go 语言的一种常见模式，确保类型正确地实现了接口。这里的 _ Fetcher = (*client)(nil) 是一个编译时的断言，如果 client 没有实现 Fetcher 接口，程序会编译失败。
 var _ Fetcher = (*client)(nil)
+var _ ContextFetcher = (*client)(nil)
+var _ BatchFetcher = (*client)(nil)
+var _ Updater = (*client)(nil)
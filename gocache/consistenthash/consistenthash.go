@@ -2,6 +2,7 @@ package consistenthash
 
 import (
 	"hash/crc32"
+	"math"
 	"sort"
 	"strconv"
 )
@@ -16,20 +17,146 @@ type Consistency struct {
 	replicas	int		    //虚拟节点的倍数
 	keys	[]int  // Sorted	//哈希环
 	hashMap	map[int]string	//虚拟节点与真实节点的映射表hashMap,键是虚拟节点的哈希值，值是真实节点的名称
+	claims	map[int][]string	//发生哈希冲突的位置上所有的真实节点，按名称排序，排在第一个的节点拥有该位置
+	weights	map[string]int	//每个真实节点的权重，虚拟节点数为 replicas*weight
+
+	loadFactor	float64		//有界负载的系数，0 代表不限制
+	loads	map[string]int64	//每个真实节点进行中的请求数
+	totalLoad	int64		//所有节点进行中的请求数之和
 }
 
-//Register将各个peer注册到哈希环上
+//Register将各个peer注册到哈希环上，新peer的权重为1
+// 已注册的 peer 保持原有的权重，重复注册不会改变哈希环
 func (c *Consistency) Register(peers ...string) {
-	for _,key := range peers {
-		for i:= 0;i < c.replicas;i++ {
-			hash := int(c.hash([]byte(strconv.Itoa(i) + key)))
-			c.keys = append(c.keys, hash)
-			c.hashMap[hash] = key
+	for _, peer := range peers {
+		if _, ok := c.weights[peer]; ok {
+			continue
 		}
+		c.addReplicas(peer, 0, c.replicas)
+		c.weights[peer] = 1
 	}
 	sort.Ints(c.keys)
 }
 
+// RegisterWeighted 以 weight 注册 peer，peer 的虚拟节点数为 replicas*weight，分到的 key 与权重成正比
+// peer 已经注册时等同于 SetWeight
+func (c *Consistency) RegisterWeighted(peer string, weight int) {
+	c.SetWeight(peer, weight)
+}
+
+// SetWeight 原地修改 peer 的权重，peer 未注册时以该权重注册，weight <= 0 时删除 peer
+// 第 i 个虚拟节点的哈希值只与 i 有关，因此增大权重只添加虚拟节点、减小权重只删除编号最大的虚拟节点，
+// 只有新增或删除的虚拟节点所在区间的 key 会移动
+func (c *Consistency) SetWeight(peer string, weight int) {
+	old, ok := c.weights[peer]
+	if weight <= 0 {
+		if ok {
+			c.Remove(peer)
+		}
+		return
+	}
+	switch {
+	case weight > old:
+		c.addReplicas(peer, old*c.replicas, weight*c.replicas)
+		sort.Ints(c.keys)
+	case weight < old:
+		c.removeReplicas(peer, weight*c.replicas, old*c.replicas)
+	}
+	c.weights[peer] = weight
+}
+
+// Weight 返回 peer 的权重，peer 未注册时返回 0
+func (c *Consistency) Weight(peer string) int {
+	return c.weights[peer]
+}
+
+// Fractions 返回每个 peer 负责的哈希空间占整个哈希环的比例，用于检查 key 的分布是否均衡
+// 虚拟节点负责从前一个虚拟节点(不含)到它自身(含)的区间，第一个虚拟节点同时负责环尾到环首的区间
+func (c *Consistency) Fractions() map[string]float64 {
+	fractions := make(map[string]float64, len(c.weights))
+	if len(c.keys) == 0 {
+		return fractions
+	}
+	const space = float64(math.MaxUint32) + 1
+	prev := c.keys[len(c.keys)-1] - int(space)
+	for _, hash := range c.keys {
+		fractions[c.hashMap[hash]] += float64(hash-prev) / space
+		prev = hash
+	}
+	return fractions
+}
+
+// addReplicas 添加 peer 编号为 [from, to) 的虚拟节点，调用后需要重新排序 c.keys
+func (c *Consistency) addReplicas(peer string, from, to int) {
+	for i := from; i < to; i++ {
+		c.addPoint(int(c.hash([]byte(strconv.Itoa(i)+peer))), peer)
+	}
+}
+
+// removeReplicas 删除 peer 编号为 [from, to) 的虚拟节点
+func (c *Consistency) removeReplicas(peer string, from, to int) {
+	for i := from; i < to; i++ {
+		c.removePoint(int(c.hash([]byte(strconv.Itoa(i)+peer))), peer)
+	}
+}
+
+// addPoint 让 peer 声明哈希环上的位置 hash，位置为新增时追加到 c.keys，调用后需要重新排序 c.keys
+// 多个虚拟节点的哈希值相同时，位置属于名称最小的 peer，与注册顺序无关，因此所有节点对 key 的归属总能达成一致；
+// 其余 peer 的声明保留在 c.claims 中，拥有者删除后由下一个 peer 接管
+func (c *Consistency) addPoint(hash int, peer string) {
+	owner, ok := c.hashMap[hash]
+	if !ok {
+		c.hashMap[hash] = peer
+		c.keys = append(c.keys, hash)
+		return
+	}
+	claims := c.claims[hash]
+	if claims == nil {
+		claims = []string{owner}
+	}
+	i := sort.SearchStrings(claims, peer)
+	claims = append(claims, "")
+	copy(claims[i+1:], claims[i:])
+	claims[i] = peer
+	c.claims[hash] = claims
+	c.hashMap[hash] = claims[0]
+}
+
+// removePoint 撤销 peer 对位置 hash 的一次声明，不会删除属于其他 peer 的位置
+func (c *Consistency) removePoint(hash int, peer string) {
+	if claims, ok := c.claims[hash]; ok {
+		i := sort.SearchStrings(claims, peer)
+		if i == len(claims) || claims[i] != peer {
+			return
+		}
+		claims = append(claims[:i], claims[i+1:]...)
+		if len(claims) == 1 {
+			delete(c.claims, hash)
+		} else {
+			c.claims[hash] = claims
+		}
+		c.hashMap[hash] = claims[0]
+		return
+	}
+	if owner, ok := c.hashMap[hash]; !ok || owner != peer {
+		return
+	}
+	delete(c.hashMap, hash)
+	idx := sort.SearchInts(c.keys, hash)
+	if idx < len(c.keys) && c.keys[idx] == hash {
+		c.keys = append(c.keys[:idx], c.keys[idx+1:]...)
+	}
+}
+
+// Collisions 返回因哈希冲突而被其他 peer 占据、没有出现在哈希环上的虚拟节点数
+func (c *Consistency) Collisions() int {
+	n := 0
+	for _, claims := range c.claims {
+		n += len(claims) - 1
+	}
+	return n
+}
+
 //New creates a new instance
 //构造函数 New() 允许自定义虚拟节点倍数和 Hash 函数。
 func New(replicas int,fn HashFunc) *Consistency {
@@ -37,6 +164,9 @@ func New(replicas int,fn HashFunc) *Consistency {
 		replicas: replicas,
 		hash:	fn,
 		hashMap: make(map[int]string),
+		claims:  make(map[int][]string),
+		weights: make(map[string]int),
+		loads:   make(map[string]int64),
 	}
 	if c.hash == nil {
 		c.hash = crc32.ChecksumIEEE
@@ -77,12 +207,137 @@ func (c *Consistency) GetPeer(key string) string {
 	return c.hashMap[c.keys[idx%len(c.keys)]]
 }
 
+// GetPeers 从 key 所在位置顺时针返回 n 个不同的真实节点，第一个即 GetPeer 返回的所属节点，
+// 之后的节点依次作为副本；节点数不足 n 时返回所有节点
+func (c *Consistency) GetPeers(key string, n int) []string {
+	if len(c.keys) == 0 || n <= 0 {
+		return nil
+	}
+	if n > len(c.weights) {
+		n = len(c.weights)
+	}
+	hash := int(c.hash([]byte(key)))
+	idx := sort.Search(len(c.keys), func(i int) bool {
+		return c.keys[i] >= hash
+	})
+	peers := make([]string, 0, n)
+	for i := 0; i < len(c.keys) && len(peers) < n; i++ {
+		peer := c.hashMap[c.keys[(idx+i)%len(c.keys)]]
+		if !containsPeer(peers, peer) {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// containsPeer 返回 peers 中是否有 peer，副本数很小，线性查找即可
+func containsPeer(peers []string, peer string) bool {
+	for _, p := range peers {
+		if p == peer {
+			return true
+		}
+	}
+	return false
+}
+
 // Remove use to remove a key and its virtual keys on the ring and map
+// key 未注册时什么也不做，与它哈希冲突的其他 peer 的位置不受影响
 func (c *Consistency) Remove(key string) {
-	for i := 0; i < c.replicas; i++ {
-		hash := int(c.hash([]byte(strconv.Itoa(i) + key)))
-		idx := sort.SearchInts(c.keys, hash)
-		c.keys = append(c.keys[:idx], c.keys[idx+1:]...)
-		delete(c.hashMap, hash)
+	weight, ok := c.weights[key]
+	if !ok {
+		return
+	}
+	c.removeReplicas(key, 0, weight*c.replicas)
+	delete(c.weights, key)
+	c.totalLoad -= c.loads[key]
+	delete(c.loads, key)
+}
+
+// Replace 以 old 的权重注册 new 并删除 old，例如节点更换地址时使用，返回是否发生了替换
+// old 未注册或 new 已注册时不做任何修改；old 的负载不会转移到 new
+func (c *Consistency) Replace(old, new string) bool {
+	weight, ok := c.weights[old]
+	if _, exists := c.weights[new]; !ok || exists || old == new {
+		return false
+	}
+	c.Remove(old)
+	c.SetWeight(new, weight)
+	return true
+}
+
+// SetLoadFactor 开启有界负载(consistent hashing with bounded loads)，factor 为节点负载相对平均负载的上限，例如 1.25
+// factor <= 0 时关闭，0 < factor < 1 时按 1 处理
+// 负载由调用方通过 Inc 与 Done 上报，与其他方法一样，Consistency 不是并发安全的，需要调用方加锁
+func (c *Consistency) SetLoadFactor(factor float64) {
+	if factor > 0 && factor < 1 {
+		factor = 1
+	}
+	if factor < 0 {
+		factor = 0
+	}
+	c.loadFactor = factor
+}
+
+// GetLeast 与 GetPeer 相同，但开启有界负载后会跳过负载已达上限的节点，顺时针选择下一个节点
+// 节点的负载上限为 ceil(factor * (总负载+1) * 权重 / 总权重)，所有节点上限之和不小于总负载+1，因此总能选出节点
+func (c *Consistency) GetLeast(key string) string {
+	if c.loadFactor == 0 || len(c.keys) == 0 {
+		return c.GetPeer(key)
 	}
-}
\ No newline at end of file
+	hash := int(c.hash([]byte(key)))
+	idx := sort.Search(len(c.keys), func(i int) bool {
+		return c.keys[i] >= hash
+	})
+	seen := make(map[string]bool)
+	for i := 0; i < len(c.keys) && len(seen) < len(c.weights); i++ {
+		peer := c.hashMap[c.keys[(idx+i)%len(c.keys)]]
+		if seen[peer] {
+			continue
+		}
+		seen[peer] = true
+		if c.loads[peer]+1 <= c.MaxLoad(peer) {
+			return peer
+		}
+	}
+	return c.GetPeer(key)
+}
+
+// MaxLoad 返回开启有界负载时 peer 进行中请求数的上限
+func (c *Consistency) MaxLoad(peer string) int64 {
+	total := 0
+	for _, w := range c.weights {
+		total += w
+	}
+	if total == 0 {
+		return 0
+	}
+	avg := float64(c.totalLoad+1) * float64(c.weights[peer]) / float64(total)
+	return int64(math.Ceil(avg * c.loadFactor))
+}
+
+// Inc 记录 peer 开始处理一个请求，与 Done 成对调用
+func (c *Consistency) Inc(peer string) {
+	if _, ok := c.weights[peer]; !ok {
+		return
+	}
+	c.loads[peer]++
+	c.totalLoad++
+}
+
+// Done 记录 peer 完成了一个请求
+func (c *Consistency) Done(peer string) {
+	if c.loads[peer] <= 0 {
+		return
+	}
+	c.loads[peer]--
+	c.totalLoad--
+}
+
+// Loads 返回每个节点进行中的请求数
+func (c *Consistency) Loads() map[string]int64 {
+	loads := make(map[string]int64, len(c.loads))
+	for peer, load := range c.loads {
+		loads[peer] = load
+	}
+	return loads
+}
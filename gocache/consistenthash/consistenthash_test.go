@@ -1,8 +1,14 @@
 package consistenthash
 
 import (
+	"hash/crc32"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
 	"strconv"
 	"testing"
+	"testing/quick"
 )
 
 func TestHashing(t *testing.T) {
@@ -44,3 +50,291 @@ func TestHashing(t *testing.T) {
 		}
 	}
 }
+
+func TestWeighted(t *testing.T) {
+	hash := New(100, nil)
+	hash.RegisterWeighted("a", 1)
+	hash.RegisterWeighted("b", 2)
+	hash.Register("c")
+
+	fractions := hash.Fractions()
+	sum := 0.0
+	for _, f := range fractions {
+		sum += f
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Fatalf("fractions should add up to 1, got %v", sum)
+	}
+	want := map[string]float64{"a": 0.25, "b": 0.5, "c": 0.25}
+	for peer, f := range want {
+		if math.Abs(fractions[peer]-f) > 0.08 {
+			t.Errorf("peer %s should own about %.2f of the ring, got %.3f", peer, f, fractions[peer])
+		}
+	}
+	if hash.Weight("b") != 2 || hash.Weight("c") != 1 || hash.Weight("d") != 0 {
+		t.Fatalf("unexpected weights")
+	}
+}
+
+func TestSetWeight(t *testing.T) {
+	hash := New(50, nil)
+	hash.Register("a", "b", "c")
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := "key" + strconv.Itoa(i)
+		before[key] = hash.GetPeer(key)
+	}
+
+	// 增大 b 的权重时，只有 key 从其他节点移动到 b
+	hash.SetWeight("b", 3)
+	moved := 0
+	for key, peer := range before {
+		if got := hash.GetPeer(key); got != peer {
+			moved++
+			if got != "b" {
+				t.Fatalf("%s moved from %s to %s, should only move to b", key, peer, got)
+			}
+		}
+	}
+	if moved == 0 {
+		t.Fatalf("no key moved to the heavier peer")
+	}
+
+	// 恢复权重后分布与之前完全相同
+	hash.SetWeight("b", 1)
+	for key, peer := range before {
+		if got := hash.GetPeer(key); got != peer {
+			t.Fatalf("%s should be back on %s, got %s", key, peer, got)
+		}
+	}
+
+	hash.SetWeight("b", 0)
+	if len(hash.keys) != 100 || hash.Weight("b") != 0 {
+		t.Fatalf("zero weight should remove the peer, %d virtual nodes left", len(hash.keys))
+	}
+	hash.Remove("a")
+	if len(hash.keys) != 50 || len(hash.hashMap) != 50 {
+		t.Fatalf("remove should delete every virtual node of a")
+	}
+}
+
+func TestBoundedLoad(t *testing.T) {
+	hash := New(50, nil)
+	hash.Register("a", "b", "c", "d")
+	hash.SetLoadFactor(1.25)
+
+	// 同一个热点 key 的请求全部进行中时，任何节点的负载都不超过上限
+	owner := hash.GetPeer("hot")
+	for i := 0; i < 100; i++ {
+		peer := hash.GetLeast("hot")
+		if load := hash.Loads()[peer]; load+1 > hash.MaxLoad(peer) {
+			t.Fatalf("%s picked with load %d over the bound %d", peer, load, hash.MaxLoad(peer))
+		}
+		hash.Inc(peer)
+	}
+	loads := hash.Loads()
+	for peer, load := range loads {
+		if load > 32 {
+			t.Fatalf("%s carries %d of 100 requests, should be at most 1.25 times the average", peer, load)
+		}
+	}
+	if loads[owner] == 0 {
+		t.Fatalf("owner %s should take the first requests", owner)
+	}
+
+	for peer, load := range loads {
+		for i := int64(0); i < load; i++ {
+			hash.Done(peer)
+		}
+	}
+	if hash.GetLeast("hot") != owner {
+		t.Fatalf("key should go back to its owner once the load is gone")
+	}
+	hash.Done("a")
+	if hash.Loads()["a"] != 0 {
+		t.Fatalf("load should never go below zero")
+	}
+
+	hash.SetLoadFactor(0)
+	for i := 0; i < 10; i++ {
+		hash.Inc(owner)
+	}
+	if hash.GetLeast("hot") != owner {
+		t.Fatalf("disabled bounded load should always pick the owner")
+	}
+}
+
+func TestGetPeers(t *testing.T) {
+	hash := New(50, nil)
+	if hash.GetPeers("key", 2) != nil {
+		t.Fatalf("empty ring should return no peers")
+	}
+	hash.Register("a", "b", "c", "d")
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		peers := hash.GetPeers(key, 3)
+		if len(peers) != 3 || peers[0] != hash.GetPeer(key) {
+			t.Fatalf("replicas of %s should start with the owner, got %v", key, peers)
+		}
+		if peers[0] == peers[1] || peers[0] == peers[2] || peers[1] == peers[2] {
+			t.Fatalf("replicas of %s should be distinct peers, got %v", key, peers)
+		}
+		// 所属节点离开后 key 落在第一个副本上
+		hash.Remove(peers[0])
+		if got := hash.GetPeer(key); got != peers[1] {
+			t.Fatalf("%s should move to its first replica %s, got %s", key, peers[1], got)
+		}
+		hash.Register(peers[0])
+	}
+	if peers := hash.GetPeers("key", 10); len(peers) != 4 {
+		t.Fatalf("n larger than the ring should return every peer, got %v", peers)
+	}
+}
+
+func TestHashCollision(t *testing.T) {
+	// "a" 的第 11 个虚拟节点与 "1a" 的第 1 个虚拟节点都是 "11a"
+	for _, order := range [][]string{{"a", "1a"}, {"1a", "a"}} {
+		hash := New(12, nil)
+		hash.Register(order...)
+		point := int(crc32.ChecksumIEEE([]byte("11a")))
+		if hash.Collisions() != 1 || hash.hashMap[point] != "1a" || len(hash.keys) != 23 {
+			t.Fatalf("collision should go to the smaller name whatever the order, got %s with %d collisions", hash.hashMap[point], hash.Collisions())
+		}
+		hash.Remove("1a")
+		if hash.hashMap[point] != "a" || hash.Collisions() != 0 || len(hash.keys) != 12 {
+			t.Fatalf("removing the owner should hand the point over to a, got %q", hash.hashMap[point])
+		}
+		hash.Remove("b")
+		hash.Remove("a")
+		if len(hash.keys) != 0 || len(hash.hashMap) != 0 {
+			t.Fatalf("ring should be empty, %d virtual nodes left", len(hash.keys))
+		}
+	}
+}
+
+func TestRegisterIdempotent(t *testing.T) {
+	hash := New(50, nil)
+	hash.Register("a", "b")
+	keys := append([]int(nil), hash.keys...)
+	hash.Register("a")
+	hash.Register("b", "b")
+	if !reflect.DeepEqual(hash.keys, keys) {
+		t.Fatalf("registering a peer twice should not change the ring, %d virtual nodes instead of %d", len(hash.keys), len(keys))
+	}
+	hash.RegisterWeighted("a", 4)
+	weighted := append([]int(nil), hash.keys...)
+	hash.Register("a", "b")
+	if hash.Weight("a") != 4 || !reflect.DeepEqual(hash.keys, weighted) {
+		t.Fatalf("registering a weighted peer again should keep its weight and virtual nodes")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	hash := New(50, nil)
+	hash.Register("a", "b")
+	hash.SetWeight("a", 2)
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		before[strconv.Itoa(i)] = hash.GetPeer(strconv.Itoa(i))
+	}
+	if hash.Replace("c", "d") || hash.Replace("a", "b") || hash.Replace("a", "a") {
+		t.Fatalf("replace should fail when old is missing or new already exists")
+	}
+	if !hash.Replace("a", "c") || hash.Weight("a") != 0 || hash.Weight("c") != 2 {
+		t.Fatalf("replace should move the weight of a to c")
+	}
+	for key, peer := range before {
+		// b 的 key 可能因为 c 的虚拟节点位置不同而移动，但不会有 key 留在 a 上
+		if got := hash.GetPeer(key); got == "a" || (peer == "b" && got != "b" && got != "c") {
+			t.Fatalf("%s should not be owned by %s", key, got)
+		}
+	}
+	if len(hash.keys) != 150-hash.Collisions() {
+		t.Fatalf("ring should hold the virtual nodes of b and c, got %d", len(hash.keys))
+	}
+}
+
+// 随机执行 Register、Remove、SetWeight、Replace 以及重复注册，检查哈希环的不变量：
+// 位置唯一且有序、每个位置属于声明它的名称最小的 peer、每个 peer 的声明数为 replicas*weight，
+// 并且哈希环只取决于最终的 peer 与权重，与操作顺序无关
+func TestRingProperties(t *testing.T) {
+	const replicas = 8
+	// 只保留 64 个位置，使哈希冲突频繁发生
+	small := func(data []byte) uint32 { return crc32.ChecksumIEEE(data) % 64 }
+	peers := []string{"a", "1a", "b", "11b", "c", "d"}
+
+	check := func(ops []uint16) bool {
+		hash := New(replicas, small)
+		for _, op := range ops {
+			peer := peers[int(op>>3)%len(peers)]
+			switch op % 5 {
+			case 0:
+				hash.Register(peer)
+			case 4:
+				// 重复注册已注册的 peer 不能改变它的权重与虚拟节点
+				weight, keys := hash.Weight(peer), append([]int(nil), hash.keys...)
+				hash.Register(peer)
+				if weight > 0 && (hash.Weight(peer) != weight || !reflect.DeepEqual(hash.keys, keys)) {
+					t.Logf("registering %s again changed the ring", peer)
+					return false
+				}
+			case 1:
+				hash.Remove(peer)
+			case 2:
+				hash.SetWeight(peer, int(op>>8)%4)
+			case 3:
+				hash.Replace(peer, peers[int(op>>8)%len(peers)])
+			}
+		}
+
+		if !sort.IntsAreSorted(hash.keys) || len(hash.keys) != len(hash.hashMap) {
+			t.Logf("keys are not sorted or do not match hashMap")
+			return false
+		}
+		claims := make(map[int][]string)
+		for peer, weight := range hash.weights {
+			for i := 0; i < replicas*weight; i++ {
+				point := int(small([]byte(strconv.Itoa(i) + peer)))
+				claims[point] = append(claims[point], peer)
+			}
+		}
+		if len(claims) != len(hash.keys) {
+			t.Logf("ring has %d points, want %d", len(hash.keys), len(claims))
+			return false
+		}
+		for point, owners := range claims {
+			sort.Strings(owners)
+			if hash.hashMap[point] != owners[0] {
+				t.Logf("point %d owned by %s, want %s", point, hash.hashMap[point], owners[0])
+				return false
+			}
+		}
+
+		// 以相反的顺序重新构建哈希环，结果应完全一致
+		names := make([]string, 0, len(hash.weights))
+		for peer := range hash.weights {
+			names = append(names, peer)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(names)))
+		rebuilt := New(replicas, small)
+		for _, peer := range names {
+			rebuilt.SetWeight(peer, hash.weights[peer])
+		}
+		sameKeys := len(hash.keys) == len(rebuilt.keys) && (len(hash.keys) == 0 || reflect.DeepEqual(hash.keys, rebuilt.keys))
+		if !sameKeys || !reflect.DeepEqual(hash.hashMap, rebuilt.hashMap) {
+			t.Logf("ring depends on the order of operations")
+			return false
+		}
+		for i := 0; i < 100; i++ {
+			if key := strconv.Itoa(i); hash.GetPeer(key) != rebuilt.GetPeer(key) {
+				t.Logf("%s is owned by different peers", key)
+				return false
+			}
+		}
+		return hash.Collisions() == rebuilt.Collisions()
+	}
+	cfg := &quick.Config{MaxCount: 500, Rand: rand.New(rand.NewSource(1))}
+	if err := quick.Check(check, cfg); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,45 @@
+package consistenthash
+
+// Jump 实现 jump consistent hash(Lamping & Veach)：以 O(log n) 的耗时、不占用额外内存将 key 映射到编号为 [0, n) 的桶
+// 节点按名称排序后编号，只有新节点排在最后、或删除最后一个节点时才只移动必须移动的 key；
+// 在中间插入或删除节点会改变其后所有节点的编号，移动的 key 较多，适合节点按编号扩缩容的部署
+type Jump struct {
+	peers peerSet
+}
+
+// NewJump 创建没有节点的 Jump
+func NewJump() *Jump {
+	return &Jump{}
+}
+
+func (j *Jump) GetPeer(key string) string {
+	if len(j.peers) == 0 {
+		return ""
+	}
+	return j.peers[jumpHash(hash64(key), len(j.peers))]
+}
+
+func (j *Jump) Add(peers ...string) {
+	j.peers.add(peers...)
+}
+
+func (j *Jump) Remove(peer string) {
+	j.peers.remove(peer)
+}
+
+func (j *Jump) Peers() []string {
+	return j.peers.list()
+}
+
+// jumpHash 返回 key 所在的桶，桶的编号为 [0, buckets)
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+var _ Placement = (*Jump)(nil)
@@ -0,0 +1,95 @@
+package consistenthash
+
+// Maglev 实现 Maglev 哈希(Google 的负载均衡器使用的算法)：每个节点按自己的排列轮流填充大小为 M 的查找表，
+// 查找只需一次取模，各节点分到的表项数最多相差 1，节点变化时大部分 key 保持不变，但不保证最少移动
+// 每次 Add 或 Remove 都会重建查找表，耗时为 O(M)
+type Maglev struct {
+	size  uint64 // 查找表的大小，是质数时每个节点的候选表项才能覆盖整张表
+	peers peerSet
+	table []int // 表项对应的节点在 peers 中的下标
+}
+
+// DefaultMaglevSize 是查找表的默认大小，建议至少为节点数的 100 倍
+const DefaultMaglevSize = 65537
+
+// NewMaglev 创建查找表大小为 size 的 Maglev，size 不是质数时使用大于它的最小质数，<= 1 时使用 DefaultMaglevSize
+func NewMaglev(size int) *Maglev {
+	if size <= 1 {
+		size = DefaultMaglevSize
+	}
+	for !isPrime(size) {
+		size++
+	}
+	return &Maglev{size: uint64(size)}
+}
+
+func isPrime(n int) bool {
+	if n < 2 {
+		return false
+	}
+	for i := 2; i*i <= n; i++ {
+		if n%i == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Maglev) GetPeer(key string) string {
+	if len(m.peers) == 0 {
+		return ""
+	}
+	return m.peers[m.table[hash64(key)%m.size]]
+}
+
+func (m *Maglev) Add(peers ...string) {
+	if m.peers.add(peers...) {
+		m.populate()
+	}
+}
+
+func (m *Maglev) Remove(peer string) {
+	if m.peers.remove(peer) {
+		m.populate()
+	}
+}
+
+func (m *Maglev) Peers() []string {
+	return m.peers.list()
+}
+
+// populate 重建查找表：节点 i 的第 j 个候选表项为 (offset_i + j*skip_i) mod M，
+// 各节点轮流取自己下一个未被占用的候选表项，直到表被填满
+func (m *Maglev) populate() {
+	n := len(m.peers)
+	if n == 0 {
+		m.table = nil
+		return
+	}
+	offsets, skips, next := make([]uint64, n), make([]uint64, n), make([]uint64, n)
+	for i, peer := range m.peers {
+		offsets[i] = hash64("offset", peer) % m.size
+		skips[i] = hash64("skip", peer)%(m.size-1) + 1
+	}
+	table := make([]int, m.size)
+	for i := range table {
+		table[i] = -1
+	}
+	for filled := uint64(0); ; {
+		for i := 0; i < n; i++ {
+			c := (offsets[i] + next[i]*skips[i]) % m.size
+			for table[c] >= 0 {
+				next[i]++
+				c = (offsets[i] + next[i]*skips[i]) % m.size
+			}
+			table[c] = i
+			next[i]++
+			if filled++; filled == m.size {
+				m.table = table
+				return
+			}
+		}
+	}
+}
+
+var _ Placement = (*Maglev)(nil)
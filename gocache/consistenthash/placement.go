@@ -0,0 +1,117 @@
+package consistenthash
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+// placement模块定义 key 到节点的放置策略
+// 除哈希环 Consistency 外还提供 rendezvous、jump 与 Maglev 三种实现，它们在查找耗时、均衡性以及节点变化时移动的 key 数量上各有取舍
+
+// Placement 是 key 的放置策略，实现不需要是并发安全的，由调用方加锁
+type Placement interface {
+	// GetPeer 返回 key 所属的节点，没有节点时返回空字符串
+	GetPeer(key string) string
+	// Add 添加节点，已存在的节点保持不变
+	Add(peers ...string)
+	// Remove 删除节点
+	Remove(peer string)
+	// Peers 返回所有节点，按名称排序
+	Peers() []string
+}
+
+// Weighted 是支持按权重分配 key 的 Placement
+type Weighted interface {
+	Placement
+	// SetWeight 修改节点的权重，节点不存在时以该权重添加，weight <= 0 时删除节点
+	SetWeight(peer string, weight int)
+}
+
+// Bounded 是支持有界负载的 Placement，负载由调用方通过 Inc 与 Done 上报
+type Bounded interface {
+	Placement
+	SetLoadFactor(factor float64)
+	GetLeast(key string) string
+	Inc(peer string)
+	Done(peer string)
+	Loads() map[string]int64
+}
+
+// Replicated 是能为 key 选出多个副本节点的 Placement
+type Replicated interface {
+	Placement
+	// GetPeers 返回 key 的 n 个不同节点，按优先级排列，第一个与 GetPeer 相同；节点数不足 n 时返回所有节点
+	GetPeers(key string, n int) []string
+}
+
+// Add 以权重 1 添加尚未注册的节点，已注册节点的权重保持不变，与 Register 相同
+func (c *Consistency) Add(peers ...string) {
+	c.Register(peers...)
+}
+
+// Peers 返回所有节点，按名称排序
+func (c *Consistency) Peers() []string {
+	peers := make([]string, 0, len(c.weights))
+	for peer := range c.weights {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+// hash64 是 rendezvous、jump 与 Maglev 使用的 64 位哈希：FNV-1a 之后再经过 splitmix64 的混合，
+// 使只有末尾几个字节不同的输入也能得到分布均匀的结果
+func hash64(parts ...string) uint64 {
+	h := fnv.New64a()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0}) // 分隔符，避免 ("ab","c") 与 ("a","bc") 相同
+	}
+	return mix64(h.Sum64())
+}
+
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// peerSet 保存排好序的节点，供 rendezvous、jump 与 Maglev 使用
+type peerSet []string
+
+// add 添加不存在的节点，返回是否发生变化
+func (s *peerSet) add(peers ...string) bool {
+	changed := false
+	for _, peer := range peers {
+		i := sort.SearchStrings(*s, peer)
+		if i < len(*s) && (*s)[i] == peer {
+			continue
+		}
+		*s = append(*s, "")
+		copy((*s)[i+1:], (*s)[i:])
+		(*s)[i] = peer
+		changed = true
+	}
+	return changed
+}
+
+// remove 删除节点，返回是否发生变化
+func (s *peerSet) remove(peer string) bool {
+	i := sort.SearchStrings(*s, peer)
+	if i == len(*s) || (*s)[i] != peer {
+		return false
+	}
+	*s = append((*s)[:i], (*s)[i+1:]...)
+	return true
+}
+
+func (s peerSet) list() []string {
+	return append([]string(nil), s...)
+}
+
+var _ Weighted = (*Consistency)(nil)
+var _ Bounded = (*Consistency)(nil)
+var _ Replicated = (*Consistency)(nil)
@@ -0,0 +1,178 @@
+package consistenthash
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// strategies 返回用于比较的放置策略
+func strategies() map[string]func() Placement {
+	return map[string]func() Placement{
+		"ring":       func() Placement { return New(100, nil) },
+		"rendezvous": func() Placement { return NewRendezvous() },
+		"jump":       func() Placement { return NewJump() },
+		"maglev":     func() Placement { return NewMaglev(0) },
+	}
+}
+
+func peerNames(n int) []string {
+	peers := make([]string, n)
+	for i := range peers {
+		peers[i] = fmt.Sprintf("10.0.0.%02d:9999", i)
+	}
+	return peers
+}
+
+// assignments 返回 keys 个 key 各自所属的节点
+func assignments(p Placement, keys int) []string {
+	owners := make([]string, keys)
+	for i := range owners {
+		owners[i] = p.GetPeer("key" + strconv.Itoa(i))
+	}
+	return owners
+}
+
+func TestPlacementBasics(t *testing.T) {
+	for name, newPlacement := range strategies() {
+		p := newPlacement()
+		if p.GetPeer("key") != "" {
+			t.Errorf("%s: empty placement should return no peer", name)
+		}
+		p.Add("b:1", "a:1", "b:1")
+		if !reflect.DeepEqual(p.Peers(), []string{"a:1", "b:1"}) {
+			t.Errorf("%s: unexpected peers %v", name, p.Peers())
+		}
+		if peer := p.GetPeer("key"); peer != "a:1" && peer != "b:1" {
+			t.Errorf("%s: unexpected peer %q", name, peer)
+		}
+		p.Remove("a:1")
+		p.Remove("c:1")
+		if p.GetPeer("key") != "b:1" || len(p.Peers()) != 1 {
+			t.Errorf("%s: only b:1 should be left, got %v", name, p.Peers())
+		}
+	}
+}
+
+// 测试 key 在节点间的分布，以及增删节点时移动的 key 的比例
+func TestPlacementDistribution(t *testing.T) {
+	const peers, keys = 10, 100000
+	// 最多的节点比平均值多出的比例上限，虚拟节点为 100 的哈希环最不均衡
+	maxSkew := map[string]float64{"ring": 0.4, "rendezvous": 0.05, "jump": 0.05, "maglev": 0.05}
+	for name, newPlacement := range strategies() {
+		p := newPlacement()
+		p.Add(peerNames(peers)...)
+		before := assignments(p, keys)
+
+		counts := make(map[string]int)
+		for _, peer := range before {
+			counts[peer]++
+		}
+		mean := float64(keys) / peers
+		skew := 0.0
+		for _, n := range counts {
+			skew = math.Max(skew, math.Abs(float64(n)-mean)/mean)
+		}
+		if len(counts) != peers || skew > maxSkew[name] {
+			t.Errorf("%s: %d peers own keys, max skew %.3f", name, len(counts), skew)
+		}
+
+		// 新节点排在最后，jump 也只需要移动分给新节点的 key
+		p.Add("10.0.0.99:9999")
+		after := assignments(p, keys)
+		moved, misplaced := 0, 0
+		for i := range before {
+			if before[i] != after[i] {
+				moved++
+				if after[i] != "10.0.0.99:9999" {
+					misplaced++
+				}
+			}
+		}
+		ideal := float64(keys) / (peers + 1)
+		if float64(moved) > 1.5*ideal || (name != "maglev" && misplaced > 0) {
+			t.Errorf("%s: adding a peer moved %d keys (%d not to the new peer), ideal %.0f", name, moved, misplaced, ideal)
+		}
+
+		// 删除中间的节点
+		p.Remove("10.0.0.99:9999")
+		p.Remove("10.0.0.03:9999")
+		removed := 0
+		for i, peer := range assignments(p, keys) {
+			if peer != before[i] {
+				removed++
+			}
+		}
+		t.Logf("%-10s skew %.3f, add moved %.3f, remove moved %.3f", name, skew, float64(moved)/keys, float64(removed)/keys)
+		if name == "ring" || name == "rendezvous" {
+			if removed != counts["10.0.0.03:9999"] {
+				t.Errorf("%s: removing a peer should only move its own %d keys, moved %d", name, counts["10.0.0.03:9999"], removed)
+			}
+		}
+	}
+}
+
+func TestRendezvousGetPeers(t *testing.T) {
+	r := NewRendezvous()
+	r.Add(peerNames(5)...)
+	for i := 0; i < 100; i++ {
+		key := "key" + strconv.Itoa(i)
+		peers := r.GetPeers(key, 3)
+		if len(peers) != 3 || peers[0] != r.GetPeer(key) {
+			t.Fatalf("replicas of %s should start with the owner, got %v", key, peers)
+		}
+		r.Remove(peers[0])
+		if got := r.GetPeers(key, 2); !reflect.DeepEqual(got, peers[1:]) {
+			t.Fatalf("replicas of %s should shift after the owner leaves, want %v got %v", key, peers[1:], got)
+		}
+		r.Add(peers[0])
+	}
+}
+
+func TestMaglevSize(t *testing.T) {
+	if m := NewMaglev(100); m.size != 101 {
+		t.Fatalf("size should be rounded up to a prime, got %d", m.size)
+	}
+	m := NewMaglev(13)
+	m.Add(peerNames(3)...)
+	counts := make(map[int]int)
+	for _, i := range m.table {
+		counts[i]++
+	}
+	for i := 0; i < 3; i++ {
+		if counts[i] < 4 || counts[i] > 5 {
+			t.Fatalf("entries of the lookup table should differ by at most one, got %v", counts)
+		}
+	}
+}
+
+func BenchmarkGetPeer(b *testing.B) {
+	for _, peers := range []int{10, 100} {
+		for _, name := range []string{"ring", "rendezvous", "jump", "maglev"} {
+			p := strategies()[name]()
+			p.Add(peerNames(peers)...)
+			keys := make([]string, 1024)
+			for i := range keys {
+				keys[i] = "key" + strconv.Itoa(i)
+			}
+			b.Run(fmt.Sprintf("%s/%d", name, peers), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					p.GetPeer(keys[i%len(keys)])
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkAddPeer(b *testing.B) {
+	for _, name := range []string{"ring", "rendezvous", "jump", "maglev"} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				p := strategies()[name]()
+				p.Add(peerNames(100)...)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package consistenthash
+
+import "sort"
+
+// Rendezvous 实现 rendezvous(最高随机权重, HRW)哈希：key 属于 hash(peer, key) 最大的节点
+// 不需要虚拟节点就能均匀分布，节点变化时只移动必须移动的 key，但每次查找需要对所有节点计算一次哈希，耗时为 O(n)
+type Rendezvous struct {
+	peers peerSet
+}
+
+// NewRendezvous 创建没有节点的 Rendezvous
+func NewRendezvous() *Rendezvous {
+	return &Rendezvous{}
+}
+
+func (r *Rendezvous) GetPeer(key string) string {
+	var best string
+	var bestScore uint64
+	for i, peer := range r.peers {
+		if score := hash64(peer, key); i == 0 || score > bestScore {
+			best, bestScore = peer, score
+		}
+	}
+	return best
+}
+
+// GetPeers 返回 hash(peer, key) 最大的 n 个节点，按哈希值从大到小排列
+// 删除某个节点后，它的 key 正好落在各自排在第二位的节点上，因此副本就是故障转移的目标
+func (r *Rendezvous) GetPeers(key string, n int) []string {
+	if n <= 0 || len(r.peers) == 0 {
+		return nil
+	}
+	scores := make([]uint64, len(r.peers))
+	order := make([]int, len(r.peers))
+	for i, peer := range r.peers {
+		scores[i], order[i] = hash64(peer, key), i
+	}
+	// 分数相同时保持节点的顺序，与 GetPeer 选出同一个节点
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	if n > len(order) {
+		n = len(order)
+	}
+	peers := make([]string, n)
+	for i := range peers {
+		peers[i] = r.peers[order[i]]
+	}
+	return peers
+}
+
+func (r *Rendezvous) Add(peers ...string) {
+	r.peers.add(peers...)
+}
+
+func (r *Rendezvous) Remove(peer string) {
+	r.peers.remove(peer)
+}
+
+func (r *Rendezvous) Peers() []string {
+	return r.peers.list()
+}
+
+var _ Replicated = (*Rendezvous)(nil)
@@ -0,0 +1,179 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// getter 模块定义 Group 从数据源加载数据的接口
+// 用户实现 Getter 即可，按需再实现下面的扩展接口，NewGroup 会自动识别并使用它们
+
+// A Getter loads data for a key
+// 定义接口 Getter 和 回调函数 Retrieve(key string)([]byte, error)，参数是 key，返回值是 []byte。
+type Getter interface {
+	Retrieve(key string) ([]byte, error)
+}
+
+// A GetterFunc implements Getter with a function
+// 定义函数类型 GetterFunc，并实现 Getter 接口的 Retrieve 方法。任何具有相应签名的函数都可以被视为一个 GetterFunc。
+type GetterFunc func(key string) ([]byte, error)
+
+// Retrieve implements Getter interface function
+// GetterFunc 还定义了 Retrieve 方法，并在 Retrieve 方法中调用自己
+// 函数类型实现某一个接口，称之为接口型函数，方便使用者在调用时既能够传入函数作为参数，也能够传入实现了该接口的结构体作为参数。
+func (f GetterFunc) Retrieve(key string) ([]byte, error) {
+	return f(key)
+}
+
+// A GetterWithContext loads data for a key within the caller's deadline
+// Getter 同时实现 GetterWithContext 时，Group 会优先调用 RetrieveContext，
+// 调用方的 deadline 和取消信号会经 singleflight 传递到 ctx 中。
+type GetterWithContext interface {
+	RetrieveContext(ctx context.Context, key string) ([]byte, error)
+}
+
+// A GetterWithContextFunc implements Getter and GetterWithContext with a function
+type GetterWithContextFunc func(ctx context.Context, key string) ([]byte, error)
+
+// RetrieveContext implements GetterWithContext interface function
+func (f GetterWithContextFunc) RetrieveContext(ctx context.Context, key string) ([]byte, error) {
+	return f(ctx, key)
+}
+
+// Retrieve implements Getter interface function
+func (f GetterWithContextFunc) Retrieve(key string) ([]byte, error) {
+	return f(context.Background(), key)
+}
+
+// A TTLGetter loads data for a key together with its time to live
+// 返回的 ttl 大于 0 时覆盖 Group 的 Expire，只对该 key 生效
+type TTLGetter interface {
+	RetrieveTTL(ctx context.Context, key string) (value []byte, ttl time.Duration, err error)
+}
+
+// An ExpiryGetter loads data for a key together with the moment it expires
+// 返回的 expire 非零时覆盖 Group 的 Expire，适用于数据源本身带有截止时间的场景
+type ExpiryGetter interface {
+	RetrieveExpiry(ctx context.Context, key string) (value []byte, expire time.Time, err error)
+}
+
+// A LookupGetter loads data for a key and reports whether it exists
+// found 为 false 代表数据源中没有该 key，Group 会返回 *NotFoundError 而不是普通错误
+// Lookup 无法返回过期时间，值总是使用 Group 的 Expire；需要按 key 设置过期时间时改为实现 ExpiryGetter 或 TTLGetter，
+// 并以返回 ErrNotFound 表示 key 不存在，效果与 found 为 false 相同
+type LookupGetter interface {
+	Lookup(ctx context.Context, key string) (value []byte, found bool, err error)
+}
+
+// A BatchGetter loads many keys in one round trip
+// 返回值中缺失的 key 视为不存在，Group.GetMulti 会用它加载本节点未命中的 key，值使用 Group 的 Expire
+type BatchGetter interface {
+	RetrieveBatch(ctx context.Context, keys []string) (map[string][]byte, error)
+}
+
+// A BatchExpiryGetter loads many keys in one round trip together with the moment each of them expires
+// expires 中缺失或为零值的 key 使用 Group 的 Expire，Getter 同时实现 BatchGetter 时优先使用它
+type BatchExpiryGetter interface {
+	RetrieveBatchExpiry(ctx context.Context, keys []string) (values map[string][]byte, expires map[string]time.Time, err error)
+}
+
+// ErrNotFound 表示数据源中不存在该 key
+// Getter 返回它(或包装了它的错误)时，Group 会在 mainCache 中缓存一个墓碑值，
+// 在 WithNegativeTTL 指定的时间内不再访问数据源。
+var ErrNotFound = errors.New("gocache: key not found")
+
+// NotFoundError 表示数据源中不存在该 key，errors.Is(err, ErrNotFound) 对它成立
+type NotFoundError struct {
+	Group string
+	Key   string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s/%s not found", e.Group, e.Key)
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return ErrNotFound
+}
+
+// loader 在 NewGroup 时识别 Getter 实现了哪些扩展接口
+// 加载单个 key 时的优先级为 LookupGetter > ExpiryGetter > TTLGetter > GetterWithContext > Getter，
+// 因此同时实现了 LookupGetter 的 Getter 不会用到 ExpiryGetter 与 TTLGetter 返回的过期时间；
+// 批量加载时的优先级为 BatchExpiryGetter > BatchGetter
+type loader struct {
+	getter      Getter
+	ctx         GetterWithContext
+	expiry      ExpiryGetter
+	ttl         TTLGetter
+	lookup      LookupGetter
+	batch       BatchGetter
+	batchExpiry BatchExpiryGetter
+}
+
+func newLoader(getter Getter) *loader {
+	l := &loader{getter: getter}
+	l.ctx, _ = getter.(GetterWithContext)
+	l.expiry, _ = getter.(ExpiryGetter)
+	l.ttl, _ = getter.(TTLGetter)
+	l.lookup, _ = getter.(LookupGetter)
+	l.batch, _ = getter.(BatchGetter)
+	l.batchExpiry, _ = getter.(BatchExpiryGetter)
+	return l
+}
+
+// load 从数据源加载 key，TTLGetter 返回的 ttl 会换算成过期时刻，零值代表使用 Group 的 Expire
+func (l *loader) load(ctx context.Context, group string, key string) (value []byte, expire time.Time, err error) {
+	switch {
+	case l.lookup != nil:
+		value, found, err := l.lookup.Lookup(ctx, key)
+		if err == nil && !found {
+			err = &NotFoundError{Group: group, Key: key}
+		}
+		return value, time.Time{}, err
+	case l.expiry != nil:
+		return l.expiry.RetrieveExpiry(ctx, key)
+	case l.ttl != nil:
+		value, ttl, err := l.ttl.RetrieveTTL(ctx, key)
+		if err == nil && ttl > 0 {
+			expire = time.Now().Add(ttl)
+		}
+		return value, expire, err
+	case l.ctx != nil:
+		value, err = l.ctx.RetrieveContext(ctx, key)
+		return value, time.Time{}, err
+	default:
+		value, err = l.getter.Retrieve(key)
+		return value, time.Time{}, err
+	}
+}
+
+// batched 返回 Getter 是否支持批量加载
+func (l *loader) batched() bool {
+	return l.batch != nil || l.batchExpiry != nil
+}
+
+// loadBatch 通过 BatchExpiryGetter 或 BatchGetter 一次加载多个 key，返回值中缺失的 key 对应 *NotFoundError
+// 返回的值带有数据源给出的过期时刻，零值代表使用 Group 的 Expire
+func (l *loader) loadBatch(ctx context.Context, group string, keys []string) (map[string]ByteView, map[string]error) {
+	values, errs := make(map[string]ByteView, len(keys)), make(map[string]error)
+	var found map[string][]byte
+	var expires map[string]time.Time
+	var err error
+	if l.batchExpiry != nil {
+		found, expires, err = l.batchExpiry.RetrieveBatchExpiry(ctx, keys)
+	} else {
+		found, err = l.batch.RetrieveBatch(ctx, keys)
+	}
+	for _, key := range keys {
+		if err != nil {
+			errs[key] = err
+		} else if v, ok := found[key]; ok {
+			values[key] = ByteView{b: cloneBytes(v), expire: expires[key]}
+		} else {
+			errs[key] = &NotFoundError{Group: group, Key: key}
+		}
+	}
+	return values, errs
+}
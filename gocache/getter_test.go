@@ -0,0 +1,161 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// scoreLoader 模拟一个以结构体实现的数据源
+type scoreLoader struct {
+	db    map[string]string
+	loads int
+}
+
+func (l *scoreLoader) Retrieve(key string) ([]byte, error) {
+	return nil, errors.New("Lookup should be preferred over Retrieve")
+}
+
+func (l *scoreLoader) Lookup(ctx context.Context, key string) ([]byte, bool, error) {
+	l.loads++
+	v, ok := l.db[key]
+	return []byte(v), ok, nil
+}
+
+// ttlLoader 为每个 key 返回不同的过期时间
+type ttlLoader struct{}
+
+func (ttlLoader) Retrieve(key string) ([]byte, error) {
+	return nil, errors.New("RetrieveTTL should be preferred over Retrieve")
+}
+
+func (ttlLoader) RetrieveTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	if key == "short" {
+		return []byte(key), 50 * time.Millisecond, nil
+	}
+	return []byte(key), 0, nil
+}
+
+// expiryLoader 返回数据源中记录的过期时刻
+type expiryLoader struct {
+	expire time.Time
+}
+
+func (l expiryLoader) Retrieve(key string) ([]byte, error) {
+	return nil, errors.New("RetrieveExpiry should be preferred over Retrieve")
+}
+
+func (l expiryLoader) RetrieveExpiry(ctx context.Context, key string) ([]byte, time.Time, error) {
+	return []byte(key), l.expire, nil
+}
+
+func TestLookupGetter(t *testing.T) {
+	l := &scoreLoader{db: map[string]string{"Tom": "630"}}
+	g := NewGroup("lookupGroup", 1<<10, time.Minute, l)
+
+	if v, err := g.Get("Tom"); err != nil || v.String() != "630" {
+		t.Fatalf("Get Tom failed: %q %v", v.String(), err)
+	}
+	_, err := g.Get("Sam")
+	var nf *NotFoundError
+	if !errors.As(err, &nf) || nf.Key != "Sam" || nf.Group != "lookupGroup" {
+		t.Fatalf("missing key should yield *NotFoundError, got %v", err)
+	}
+}
+
+func TestTTLGetter(t *testing.T) {
+	g := NewGroup("ttlGroup", 1<<10, time.Minute, ttlLoader{})
+
+	start := time.Now()
+	for _, key := range []string{"short", "long"} {
+		if v, err := g.Get(key); err != nil || v.String() != key {
+			t.Fatalf("Get %s failed: %q %v", key, v.String(), err)
+		}
+	}
+	if v, _ := g.mainCache.get("short"); v.Expire().Before(start.Add(50*time.Millisecond)) || v.Expire().After(time.Now().Add(50*time.Millisecond)) {
+		t.Fatalf("short should expire 50ms after loading, got %v", v.Expire())
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := g.mainCache.get("short"); ok {
+		t.Fatalf("short should expire with the ttl returned by the loader")
+	}
+	if _, ok := g.mainCache.get("long"); !ok {
+		t.Fatalf("long should fall back to the group's Expire")
+	}
+}
+
+func TestExpiryGetter(t *testing.T) {
+	expire := time.Now().Add(50 * time.Millisecond)
+	g := NewGroup("expiryGroup", 1<<10, time.Minute, expiryLoader{expire: expire})
+
+	if v, err := g.Get("Tom"); err != nil || !v.Expire().Equal(expire) {
+		t.Fatalf("Get should return the loader's expiry %v, got %v %v", expire, v.Expire(), err)
+	}
+	time.Sleep(time.Until(expire) + 10*time.Millisecond)
+	if _, ok := g.mainCache.get("Tom"); ok {
+		t.Fatalf("Tom should expire at the moment returned by the loader")
+	}
+}
+
+func TestNewLoaderDetectsExtensions(t *testing.T) {
+	l := newLoader(&scoreLoader{})
+	if l.lookup == nil || l.ttl != nil || l.expiry != nil || l.ctx != nil || l.batch != nil {
+		t.Fatalf("newLoader detected wrong extensions: %+v", l)
+	}
+	l = newLoader(GetterWithContextFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return nil, nil
+	}))
+	if l.ctx == nil || l.lookup != nil {
+		t.Fatalf("newLoader should detect GetterWithContext: %+v", l)
+	}
+}
+
+func TestNegativeCache(t *testing.T) {
+	loads := 0
+	missing := GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		if key == "Tom" {
+			return []byte("630"), nil
+		}
+		return nil, fmt.Errorf("query %s: %w", key, ErrNotFound)
+	})
+	g := NewGroup("negativeGroup", 1<<10, time.Minute, missing, WithNegativeTTL(50*time.Millisecond))
+
+	for i := 0; i < 3; i++ {
+		if _, err := g.Get("Sam"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get Sam should return ErrNotFound, got %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("tombstone should stop repeated loads, got %d loads", loads)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err := g.Get("Sam"); !errors.Is(err, ErrNotFound) || loads != 2 {
+		t.Fatalf("tombstone should expire after the negative ttl, got %v with %d loads", err, loads)
+	}
+
+	if err := g.Set("Sam", []byte("589"), 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if v, err := g.Get("Sam"); err != nil || v.String() != "589" {
+		t.Fatalf("Set should replace the tombstone, got %q %v", v.String(), err)
+	}
+
+	values, errs := g.GetMulti(context.Background(), []string{"Tom", "Jack", "Jack"})
+	if values["Tom"].String() != "630" || !errors.Is(errs["Jack"], ErrNotFound) {
+		t.Fatalf("unexpected GetMulti result %v %v", values, errs)
+	}
+	if _, errs = g.GetMulti(context.Background(), []string{"Jack"}); !errors.Is(errs["Jack"], ErrNotFound) || loads != 4 {
+		t.Fatalf("GetMulti should hit the tombstone, got %v with %d loads", errs, loads)
+	}
+
+	loads = 0
+	g = NewGroup("negativeDisabled", 1<<10, time.Minute, missing, WithNegativeTTL(0))
+	g.Get("Sam")
+	g.Get("Sam")
+	if loads != 2 {
+		t.Fatalf("negative caching should be disabled, got %d loads", loads)
+	}
+}
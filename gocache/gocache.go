@@ -1,47 +1,46 @@
 package gocache
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	// pb "gocache/gocachepb"
 	"gocache/singleflight"
-	"log"
+	"math/rand"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 // gocache 模块提供比cache模块更高一层抽象的能力
 // 换句话说，实现了填充缓存/命名划分缓存的能力
 
 
-// A Getter loads data for a key
-// 定义接口 Getter 和 回调函数 Get(key string)([]byte, error)，参数是 key，返回值是 []byte。
-type Getter interface {
-	retrieve(key string) ([]byte, error)
-}
-
-// A GetterFunc implements Getter with a function
-// 定义函数类型 GetterFunc，并实现 Getter 接口的 Get 方法。任何具有相应签名的函数都可以被视为一个 GetterFunc。
-type GetterFunc func(key string) ([]byte, error)
-
-// Get implements Getter interface function
-// GetterFunc 还定义了 Get 方式，并在 Get 方法中调用自己
-// 函数类型实现某一个接口，称之为接口型函数，方便使用者在调用时既能够传入函数作为参数，也能够传入实现了该接口的结构体作为参数。
-func (f GetterFunc) retrieve(key string) ([]byte, error) {
-	return f(key)
-}
-
 // A Group is a cache namespace and associated data loaded spread over
 // Group 是 GoCache 最核心的数据结构，负责与用户的交互，并且控制缓存值存储和获取的流程。
 // 一个Group可以认为是一个缓存的命名空间，每个Group拥有一个唯一的名称name,
 // 比如可以创建三个 Group，缓存学生的成绩命名为 scores，缓存学生信息的命名为 info，缓存学生课程的命名为 courses。
 type Group struct {
 	name      string
-	getter    Getter //缓存未命中时获取源数据的回调(callback)。
+	loader    *loader //缓存未命中时获取源数据的回调(callback)。
 	mainCache cache  //一开始实现的并发缓存。
+	// hotCache 存放从远端节点获取的部分热点 key 的副本，避免每次都访问远端节点
+	hotCache  cache
+	hotTTL    time.Duration // 副本最长的保存时间
+	hotRatio  float64       // 远端获取的值写入 hotCache 的比例
 	server    Picker
 	// use singleflight.Group to make sure that
 	// each key is only fetched once
 	flight    *singleflight.Flight
 	Expire    time.Duration
+	negativeTTL time.Duration // 墓碑值的过期时间，0 代表不缓存不存在的 key
+	softTTL      time.Duration // 超过该时间的值仍会返回，同时在后台刷新
+	refreshAhead time.Duration // 距离过期不足该时间时被读取的 key 会提前刷新
+	refreshing   sync.Map      // 正在后台刷新的 key
+	stats        groupStats
+	log          Logger // 为 nil 时使用 slog.Default()
 }
 
 var (
@@ -49,9 +48,73 @@ var (
 	groups = make(map[string]*Group)
 )
 
+// GroupOption 用于在 NewGroup 时定制 Group
+type GroupOption func(*Group)
+
+// WithCacheType 选择 mainCache 使用的淘汰算法，取值为 TYPE_* 常量，默认为 TYPE_SIMPLE
+// 不论哪种算法，cacheBytes 的字节数限制和 Expire 过期时间都同样生效
+func WithCacheType(cacheType string) GroupOption {
+	return func(g *Group) {
+		g.mainCache.cacheType = cacheType
+	}
+}
+
+// WithLogger 设置 Group 输出日志使用的 Logger
+func WithLogger(l Logger) GroupOption {
+	return func(g *Group) {
+		g.log = l
+	}
+}
+
+// WithMaxEntries 限制 mainCache 最多存放的条目数，对 TYPE_SIMPLE 无效
+func WithMaxEntries(n int) GroupOption {
+	return func(g *Group) {
+		g.mainCache.maxEntries = n
+	}
+}
+
+// WithNegativeTTL 设置数据源返回 ErrNotFound 时墓碑值的缓存时间，默认为 10 秒
+// d 为 0 时不缓存不存在的 key，每次请求都会访问数据源
+func WithNegativeTTL(d time.Duration) GroupOption {
+	return func(g *Group) {
+		g.negativeTTL = d
+	}
+}
+
+// WithStaleWhileRevalidate 为 mainCache 中的值设置软、硬两个过期时间
+// 超过 soft 后命中的旧值仍会立即返回，同时经 singleflight 在后台刷新一次；
+// 超过 hard 后值被淘汰，调用方阻塞等待重新加载。hard 为 0 时沿用 Group 的 Expire
+func WithStaleWhileRevalidate(soft, hard time.Duration) GroupOption {
+	return func(g *Group) {
+		g.softTTL = soft
+		if hard > 0 {
+			g.Expire = hard
+		}
+	}
+}
+
+// WithRefreshAhead 让距离过期不足 window 时仍被读取的 key 在后台提前刷新
+// 这类 key 通常是热点 key，提前刷新可以避免它们过期后调用方阻塞
+func WithRefreshAhead(window time.Duration) GroupOption {
+	return func(g *Group) {
+		g.refreshAhead = window
+	}
+}
+
+// WithHotCache 启用 hotCache，capacity 为其字节数上限
+// 从远端节点获取的值按 ratio 的比例抽样写入 hotCache，最多保存 ttl(不超过值本身的过期时间)，
+// 热点 key 被反复读取，因而更容易被抽中，而冷门 key 不会挤占空间
+func WithHotCache(capacity int64, ttl time.Duration, ratio float64) GroupOption {
+	return func(g *Group) {
+		g.hotCache.capacity = capacity
+		g.hotTTL = ttl
+		g.hotRatio = ratio
+	}
+}
+
 // NewGroup create a new instance of Group
 // 构建函数 NewGroup 用来实例化 Group，并且将 group 存储在全局变量 groups 中。
-func NewGroup(name string, cacheBytes int64, expire time.Duration, getter Getter,) *Group {
+func NewGroup(name string, cacheBytes int64, expire time.Duration, getter Getter, opts ...GroupOption) *Group {
 	if getter == nil {
 		panic("nil Getter")
 	}
@@ -59,10 +122,17 @@ func NewGroup(name string, cacheBytes int64, expire time.Duration, getter Getter
 	defer mu.Unlock()
 	g := &Group{
 		name:      name,
-		getter:    getter,
+		loader:    newLoader(getter),
 		mainCache: cache{capacity: cacheBytes},
 		flight:    &singleflight.Flight{},
 		Expire:    expire,
+		negativeTTL: defaultNegativeTTL,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if err := validCacheType(g.mainCache.cacheType); err != nil {
+		panic(err)
 	}
 	groups[name] = g
 	return g
@@ -88,13 +158,45 @@ func GetGroup(name string) *Group {
 	return g
 }
 
+// Groups 返回所有已创建的 Group，按名称排序
+func Groups() []*Group {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		list = append(list, g)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
+// Name 返回 Group 的名称
+func (g *Group) Name() string {
+	return g.name
+}
+
+// CacheType 返回 mainCache 使用的淘汰算法
+func (g *Group) CacheType() string {
+	if g.mainCache.cacheType == "" {
+		return TYPE_SIMPLE
+	}
+	return g.mainCache.cacheType
+}
+
+// logger 返回 Group 使用的 Logger
+func (g *Group) logger() Logger {
+	return loggerOrDefault(g.log)
+}
+
 func DestroyGroup(name string) {
 	g := GetGroup(name)
 	if g != nil {
 		svr  := g.server.(*server)
 		svr.Stop()
+		mu.Lock() // Groups 可能正在并发遍历 groups，例如 metrics 的 Collect
 		delete(groups,name)
-		log.Printf("Destroy cache [%s %s]", name, svr.addr)
+		mu.Unlock()
+		g.logger().Info("[GoCache] destroy cache", "group", name, "addr", svr.addr)
 	}
 }
 
@@ -110,36 +212,90 @@ func DestroyGroup(name string) {
 // 实现了流程1、3
 // 从 mainCache 中查找缓存，如果存在则返回缓存值。
 func (g *Group) Get(key string) (ByteView, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+// GetContext 与 Get 相同，但在 ctx 结束时立即返回 ctx.Err()
+// ctx 的 deadline 会传递给远端节点以及 GetterWithContext
+func (g *Group) GetContext(ctx context.Context, key string) (value ByteView, err error) {
 	if key == "" {
 		return ByteView{}, fmt.Errorf("key is required")
 	}
-	if v, ok := g.mainCache.get(key); ok {
-		log.Println("[GoCache] hit")
-		return v, nil
+	ctx, span := startSpan(ctx, "gocache.Group.Get", g.name, key)
+	defer func() { endSpan(span, err) }()
+
+	v, ok, err := g.lookupCache(key)
+	span.SetAttributes(attrCacheHit.Bool(ok))
+	if ok {
+		g.logger().Debug("[GoCache] hit", "group", g.name, "key", key)
+		return v, err
 	}
 	//缓存不存在，则调用 load 方法
-	return g.load(key)
+	return g.load(ctx, key)
+}
+
+// lookupCache 依次在 mainCache 与 hotCache 中查找 key，命中墓碑值时返回 *NotFoundError
+func (g *Group) lookupCache(key string) (value ByteView, ok bool, err error) {
+	defer g.countLookup(&ok)
+	value, ok = g.mainCache.get(key)
+	if !ok && g.hotRatio > 0 {
+		value, ok = g.hotCache.get(key)
+		return value, ok, nil
+	}
+	if ok && value.notFound {
+		return ByteView{}, true, &NotFoundError{Group: g.name, Key: key}
+	}
+	if ok && !value.refresh.IsZero() && time.Now().After(value.refresh) {
+		g.refresh(key)
+	}
+	return value, ok, nil
+}
+
+// refresh 在后台重新加载 key，同一个 key 同时只有一个刷新在进行
+// 刷新期间调用方继续得到旧值，刷新失败时旧值保留到过期为止
+func (g *Group) refresh(key string) {
+	if _, loading := g.refreshing.LoadOrStore(key, struct{}{}); loading {
+		return
+	}
+	go func() {
+		defer g.refreshing.Delete(key)
+		if _, err := g.load(context.Background(), key); err != nil {
+			g.logger().Warn("[GoCache] failed to refresh", "group", g.name, "key", key, "err", err)
+		}
+	}()
 }
 
 
 // load 调用 getLocally（分布式场景下会调用 getFromPeer 从其他节点获取）
 // 修改 load 方法，使用 PickPeer() 方法选择节点，若非本机节点，则调用 getFromPeer() 从远程获取。若是本机节点或失败，则回退到 getLocally()。
-func (g *Group) load(key string) (value ByteView, err error) {
+func (g *Group) load(ctx context.Context, key string) (value ByteView, err error) {
 	// return g.getLocally(key)
 
 	//each key is only fetched oncce(either locally or remotely)
 	//regardless of the number of concurrent callers
 	//修改 load 函数，将原来的 load 的逻辑，使用 g.loader.Do 包裹起来即可，这样确保了并发场景下针对相同的 key，load 过程只会调用一次。
-	viewi, err := g.flight.Fly(key, func() (interface{}, error) { //任何类型都满足空接口，确保func()函数只执行一次
-		if g.server != nil {
+	viewi, err := g.flight.FlyContext(ctx, key, func(ctx context.Context) (interface{}, error) { //任何类型都满足空接口，确保func()函数只执行一次
+		// 其他节点因负载转发来的请求只在本地加载
+		if g.server != nil && !redirected(ctx) {
 			if fetcher, ok := g.server.Pick(key); ok {
-				if bytes, err := fetcher.Fetch(g.name,key); err == nil {
-					return ByteView{b: cloneBytes(bytes)}, nil
+				view, err := fetch(ctx, fetcher, g.name, key)
+				g.countPeerLoad(err)
+				if err == nil {
+					g.populateHotCache(key, view)
+					return view, nil
 				}
-				log.Println("[GoCache] Failed to get from peer", err)
+				// 已超时或被取消时不再回退到本地加载
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				// 远端节点明确告知 key 不存在，本地加载也只会得到同样的结果
+				if errors.Is(err, ErrNotFound) {
+					return nil, err
+				}
+				g.logger().Warn("[GoCache] failed to get from peer", "group", g.name, "key", key, "err", err)
 			}
 		}
-		return g.getLocally(key)
+		return g.getLocally(ctx, key)
 	})
 
 	if err == nil {
@@ -148,17 +304,412 @@ func (g *Group) load(key string) (value ByteView, err error) {
 	return
 }
 
-// getLocally 调用用户回调函数 g.getter.Get() 获取源数据，并且将源数据添加到缓存 mainCache 中（通过 populateCache 方法）
-func (g *Group) getLocally(key string) (ByteView, error) {
-	bytes, err := g.getter.retrieve(key) //调用get方法时，就已经用peer的*httpGetter的内容（存的ip地址）去访问数据了。
+// fetch 在 fetcher 支持时带上 ctx 访问远端节点
+// 只实现了 Fetcher 的节点无法告知过期时间，返回的值不带过期时间
+func fetch(ctx context.Context, fetcher Fetcher, group string, key string) (value ByteView, err error) {
+	ctx, span := startSpan(ctx, "gocache.peer.Fetch", group, key, oteltrace.WithSpanKind(oteltrace.SpanKindClient))
+	span.SetAttributes(attrPeer.String(peerName(fetcher)))
+	defer func() { endSpan(span, err) }()
+
+	if f, ok := fetcher.(ContextFetcher); ok {
+		return f.FetchContext(ctx, group, key)
+	}
+	bytes, err := fetcher.Fetch(group, key)
+	if err != nil {
+		return ByteView{}, err
+	}
+	return ByteView{b: cloneBytes(bytes)}, nil
+}
+
+// GetMulti 批量获取多个 key 的值，返回每个 key 的值或错误
+// 未命中的 key 按所属节点分组，每个远端节点只发送一次批量请求；
+// 属于本节点的 key 优先使用 BatchGetter 一次性加载。
+// 与 Get 一样，远端节点整体不可用时回退到本地加载。
+func (g *Group) GetMulti(ctx context.Context, keys []string) (map[string]ByteView, map[string]error) {
+	ctx, span := tracer().Start(ctx, "gocache.Group.GetMulti")
+	span.SetAttributes(attrGroup.String(g.name), attrKeys.Int(len(keys)))
+	defer span.End()
+
+	values, errs := make(map[string]ByteView, len(keys)), make(map[string]error)
+	var local []string
+	remote := make(map[Fetcher][]string)
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if key == "" {
+			errs[key] = fmt.Errorf("key is required")
+			continue
+		}
+		if v, ok, err := g.lookupCache(key); ok {
+			if err != nil {
+				errs[key] = err
+			} else {
+				values[key] = v
+			}
+			continue
+		}
+		if g.server != nil {
+			if fetcher, ok := g.server.Pick(key); ok {
+				remote[fetcher] = append(remote[fetcher], key)
+				continue
+			}
+		}
+		local = append(local, key)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	merge := func(v map[string]ByteView, e map[string]error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for key, value := range v {
+			values[key] = value
+		}
+		for key, err := range e {
+			errs[key] = err
+		}
+	}
+	for fetcher, peerKeys := range remote {
+		wg.Add(1)
+		go func(fetcher Fetcher, peerKeys []string) {
+			defer wg.Done()
+			merge(g.fetchMulti(ctx, fetcher, peerKeys))
+		}(fetcher, peerKeys)
+	}
+	if len(local) > 0 {
+		merge(g.loadMultiLocally(ctx, local))
+	}
+	wg.Wait()
+	return values, errs
+}
+
+// fetchMulti 从一个远端节点批量获取，请求整体失败时回退到本地加载
+func (g *Group) fetchMulti(ctx context.Context, fetcher Fetcher, keys []string) (map[string]ByteView, map[string]error) {
+	values, errs := make(map[string]ByteView, len(keys)), make(map[string]error)
+	if f, ok := fetcher.(BatchFetcher); ok {
+		fctx, span := tracer().Start(ctx, "gocache.peer.FetchMulti", oteltrace.WithSpanKind(oteltrace.SpanKindClient))
+		span.SetAttributes(attrGroup.String(g.name), attrKeys.Int(len(keys)), attrPeer.String(peerName(fetcher)))
+		fetched, keyErrs, err := f.FetchMulti(fctx, g.name, keys)
+		endSpan(span, err)
+		if err == nil {
+			for key, value := range fetched {
+				g.countPeerLoad(nil)
+				g.populateHotCache(key, value)
+				values[key] = value
+			}
+			for key, err := range keyErrs {
+				g.countPeerLoad(err)
+				errs[key] = err
+			}
+			return values, errs
+		}
+		atomic.AddInt64(&g.stats.peerErrors, int64(len(keys)))
+		if ctx.Err() == nil {
+			g.logger().Warn("[GoCache] failed to get multi from peer", "group", g.name, "keys", len(keys), "err", err)
+			return g.loadMultiLocally(ctx, keys)
+		}
+		for _, key := range keys {
+			errs[key] = ctx.Err()
+		}
+		return values, errs
+	}
+	// 不支持批量请求的节点逐个获取
+	for _, key := range keys {
+		if v, err := g.load(ctx, key); err != nil {
+			errs[key] = err
+		} else {
+			values[key] = v
+		}
+	}
+	return values, errs
+}
+
+// getMultiLocally 只在本节点查找或加载多个 key，供 server 处理 GetMulti 请求
+func (g *Group) getMultiLocally(ctx context.Context, keys []string) (map[string]ByteView, map[string]error) {
+	values, errs := make(map[string]ByteView, len(keys)), make(map[string]error)
+	var misses []string
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if key == "" {
+			errs[key] = fmt.Errorf("key is required")
+		} else if v, ok, err := g.lookupCache(key); !ok {
+			misses = append(misses, key)
+		} else if err != nil {
+			errs[key] = err
+		} else {
+			values[key] = v
+		}
+	}
+	if len(misses) > 0 {
+		loaded, loadErrs := g.loadMultiLocally(ctx, misses)
+		for key, v := range loaded {
+			values[key] = v
+		}
+		for key, err := range loadErrs {
+			errs[key] = err
+		}
+	}
+	return values, errs
+}
+
+// loadMultiLocally 从数据源加载多个 key 并写入 mainCache
+// Getter 实现了 BatchGetter 或 BatchExpiryGetter 时经 singleflight 一次加载全部 key，
+// 正被 Get 加载的 key 等待其结果，否则逐个经 singleflight 加载
+func (g *Group) loadMultiLocally(ctx context.Context, keys []string) (map[string]ByteView, map[string]error) {
+	values, errs := make(map[string]ByteView, len(keys)), make(map[string]error)
+	if g.loader.batched() {
+		viewis, batchErrs := g.flight.FlyBatch(ctx, keys, g.loadBatchLocally)
+		for key, viewi := range viewis {
+			values[key] = viewi.(ByteView)
+		}
+		return values, batchErrs
+	}
+	for _, key := range keys {
+		key := key
+		viewi, err := g.flight.FlyContext(ctx, key, func(ctx context.Context) (interface{}, error) {
+			return g.getLocally(ctx, key)
+		})
+		if err != nil {
+			errs[key] = err
+			continue
+		}
+		values[key] = viewi.(ByteView)
+	}
+	return values, errs
+}
+
+// loadBatchLocally 通过批量接口从数据源加载 keys 并写入 mainCache，由 singleflight 调用
+func (g *Group) loadBatchLocally(ctx context.Context, keys []string) (map[string]interface{}, map[string]error) {
+	ctx, span := tracer().Start(ctx, "gocache.Getter.Batch")
+	span.SetAttributes(attrGroup.String(g.name), attrKeys.Int(len(keys)))
+	start := time.Now()
+	loaded, loadErrs := g.loader.loadBatch(ctx, g.name, keys)
+	var loadErr error
+	for _, err := range loadErrs {
+		if !errors.Is(err, ErrNotFound) {
+			loadErr = err
+			break
+		}
+	}
+	observeLoad(g.name, start, loadErr)
+	endSpan(span, loadErr)
+	values := make(map[string]interface{}, len(loaded))
+	for key, view := range loaded {
+		values[key] = g.populateCache(key, view, 0)
+	}
+	for key, err := range loadErrs {
+		g.countLocalLoad(err)
+		g.populateNotFound(key, err)
+	}
+	atomic.AddInt64(&g.stats.localLoads, int64(len(loaded)))
+	return values, loadErrs
+}
+
+// Set 写入 key 对应的值，ttl 为 0 时使用 Group 的 Expire
+// 若 key 属于远端节点，则写入该节点，并丢弃本地可能存在的旧值
+// server 开启副本写入时写入每个副本，本节点是副本之一时同时写入本地
+func (g *Group) Set(key string, value []byte, ttl time.Duration) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	if peers, self, ok := g.pickReplicas(key); ok {
+		errs := forEachReplica(key, peers, func(u Updater) error { return u.Set(g.name, key, value, ttl) })
+		if self {
+			g.setLocally(key, value, ttl)
+		} else {
+			g.removeLocally(key)
+		}
+		return errors.Join(errs...)
+	}
+	updater, remote, err := g.pickUpdater(key)
+	if err != nil {
+		return err
+	}
+	if remote {
+		if err := updater.Set(g.name, key, value, ttl); err != nil {
+			return err
+		}
+		g.removeLocally(key)
+		return nil
+	}
+	g.setLocally(key, value, ttl)
+	return nil
+}
+
+// Remove 删除 key 在其所属节点上的缓存值，server 开启副本写入时删除每个副本上的值
+func (g *Group) Remove(key string) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	if peers, _, ok := g.pickReplicas(key); ok {
+		errs := forEachReplica(key, peers, func(u Updater) error { return u.Remove(g.name, key) })
+		g.removeLocally(key)
+		return errors.Join(errs...)
+	}
+	updater, remote, err := g.pickUpdater(key)
+	if err != nil {
+		return err
+	}
+	if remote {
+		if err := updater.Remove(g.name, key); err != nil {
+			return err
+		}
+	}
+	g.removeLocally(key)
+	return nil
+}
+
+// Invalidate 让集群中所有节点丢弃 key 的缓存值，下次 Get 时将重新加载
+func (g *Group) Invalidate(key string) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	g.removeLocally(key)
+	lister, ok := g.server.(PeerLister)
+	if !ok {
+		return nil
+	}
+	var errs []error
+	for _, peer := range lister.Peers() {
+		if updater, ok := peer.(Updater); ok {
+			if err := updater.Invalidate(g.name, key); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pickUpdater 选出 key 所属的远端节点，remote 为 false 代表 key 属于本节点
+func (g *Group) pickUpdater(key string) (updater Updater, remote bool, err error) {
+	if g.server == nil {
+		return nil, false, nil
+	}
+	var fetcher Fetcher
+	var ok bool
+	if owner, isOwner := g.server.(OwnerPicker); isOwner {
+		fetcher, ok = owner.PickOwner(key)
+	} else {
+		fetcher, ok = g.server.Pick(key)
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	if updater, ok = fetcher.(Updater); !ok {
+		return nil, true, fmt.Errorf("peer of key %s does not support updates", key)
+	}
+	return updater, true, nil
+}
+
+// pickReplicas 在 server 开启副本写入时选出 key 的所有副本，ok 为 false 代表只写入所属节点
+func (g *Group) pickReplicas(key string) (peers []Fetcher, self bool, ok bool) {
+	picker, isReplica := g.server.(ReplicaPicker)
+	if !isReplica {
+		return nil, false, false
+	}
+	peers, self = picker.PickReplicas(key)
+	return peers, self, len(peers) > 0 || self
+}
+
+// forEachReplica 对每个远端副本执行写操作，某个副本失败时仍继续写入其余副本，返回所有失败
+func forEachReplica(key string, peers []Fetcher, write func(Updater) error) []error {
+	var errs []error
+	for _, peer := range peers {
+		updater, ok := peer.(Updater)
+		if !ok {
+			errs = append(errs, fmt.Errorf("replica %s of key %s does not support updates", peerName(peer), key))
+			continue
+		}
+		if err := write(updater); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// setLocally 将值写入本地 mainCache，并丢弃 hotCache 中的旧副本
+func (g *Group) setLocally(key string, value []byte, ttl time.Duration) {
+	g.hotCache.remove(key)
+	g.populateCache(key, ByteView{b: cloneBytes(value)}, ttl)
+}
+
+// removeLocally 删除本地 mainCache 与 hotCache 中的值
+func (g *Group) removeLocally(key string) {
+	g.mainCache.remove(key)
+	g.hotCache.remove(key)
+}
+
+// getLocally 调用用户回调函数 g.loader.load() 获取源数据，并且将源数据添加到缓存 mainCache 中（通过 populateCache 方法）
+func (g *Group) getLocally(ctx context.Context, key string) (ByteView, error) {
+	ctx, span := startSpan(ctx, "gocache.Getter", g.name, key)
+	start := time.Now()
+	bytes, expire, err := g.loader.load(ctx, g.name, key) //调用get方法时，就已经用peer的*httpGetter的内容（存的ip地址）去访问数据了。
+	observeLoad(g.name, start, err)
+	endSpan(span, err)
+	g.countLocalLoad(err)
 	if err != nil {
+		g.populateNotFound(key, err)
 		return ByteView{}, err
 	}
-	value := ByteView{b: cloneBytes(bytes)}
-	g.populateCache(key, value)
-	return value, nil
+	return g.populateCache(key, ByteView{b: cloneBytes(bytes), expire: expire}, 0), nil
 }
 
-func (g *Group) populateCache(key string, value ByteView) {
-	g.mainCache.add(key, value, g.Expire)
+// populateNotFound 在数据源返回 ErrNotFound 时缓存墓碑值，其余错误不缓存
+func (g *Group) populateNotFound(key string, err error) {
+	if g.negativeTTL <= 0 || !errors.Is(err, ErrNotFound) {
+		return
+	}
+	g.mainCache.add(key, ByteView{notFound: true, expire: time.Now().Add(g.negativeTTL)})
+}
+
+// populateCache 将值添加到 mainCache，并返回带有过期时间的值
+// value 自带过期时间(来自 loader)时直接使用，否则按 ttl 计算，ttl 为 0 时使用 Group 的 Expire
+func (g *Group) populateCache(key string, value ByteView, ttl time.Duration) ByteView {
+	if ttl <= 0 {
+		ttl = g.Expire
+	}
+	if value.expire.IsZero() && ttl > 0 {
+		value.expire = time.Now().Add(ttl)
+	}
+	value.refresh = g.refreshAt(value.expire)
+	g.mainCache.add(key, value, ttl)
+	return value
+}
+
+// populateHotCache 按 hotRatio 抽样把远端获取的值写入 hotCache
+func (g *Group) populateHotCache(key string, value ByteView) {
+	if g.hotRatio <= 0 || rand.Float64() >= g.hotRatio {
+		return
+	}
+	if g.hotTTL > 0 {
+		if expire := time.Now().Add(g.hotTTL); value.expire.IsZero() || expire.Before(value.expire) {
+			value.expire = expire
+		}
+	}
+	g.hotCache.add(key, value, 0)
+}
+
+// refreshAt 计算值开始后台刷新的时刻，取软过期时间与提前刷新时间中较早的一个
+// 该时刻不早于硬过期时间时没有意义，返回零值
+func (g *Group) refreshAt(expire time.Time) time.Time {
+	var at time.Time
+	if g.softTTL > 0 {
+		at = time.Now().Add(g.softTTL)
+	}
+	if g.refreshAhead > 0 && !expire.IsZero() {
+		if t := expire.Add(-g.refreshAhead); at.IsZero() || t.Before(at) {
+			at = t
+		}
+	}
+	if !expire.IsZero() && !at.Before(expire) {
+		return time.Time{}
+	}
+	return at
 }
@@ -1,10 +1,15 @@
 package gocache
 
 import (
+    "context"
+    "errors"
     "testing"
     "time"
+    "sync"
+    "sync/atomic"
     "fmt"
     "log"
+    "gocache/registry"
 )
 
 type String string
@@ -141,4 +146,580 @@ func TestGet(t *testing.T) {
 	} else {
 		log.Println(err)
 	}
-}
\ No newline at end of file
+}
+
+// fakePeer 模拟一个远端节点，记录收到的写操作
+type fakePeer struct {
+	values      map[string][]byte
+	expires     map[string]time.Time
+	invalidated []string
+	batches     int
+	fetches     int
+}
+
+func (p *fakePeer) Fetch(group string, key string) ([]byte, error) {
+	p.fetches++
+	if v, ok := p.values[key]; ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("%s not exist", key)
+}
+
+func (p *fakePeer) FetchContext(ctx context.Context, group string, key string) (ByteView, error) {
+	v, err := p.Fetch(group, key)
+	if err != nil {
+		return ByteView{}, err
+	}
+	return NewByteView(v, p.expires[key]), nil
+}
+
+func (p *fakePeer) FetchMulti(ctx context.Context, group string, keys []string) (map[string]ByteView, map[string]error, error) {
+	p.batches++
+	values, keyErrs := make(map[string]ByteView), make(map[string]error)
+	for _, key := range keys {
+		if v, err := p.FetchContext(ctx, group, key); err != nil {
+			keyErrs[key] = err
+		} else {
+			values[key] = v
+		}
+	}
+	return values, keyErrs, nil
+}
+
+func (p *fakePeer) Set(group string, key string, value []byte, ttl time.Duration) error {
+	p.values[key] = value
+	return nil
+}
+
+func (p *fakePeer) Remove(group string, key string) error {
+	delete(p.values, key)
+	return nil
+}
+
+func (p *fakePeer) Invalidate(group string, key string) error {
+	p.invalidated = append(p.invalidated, key)
+	return nil
+}
+
+// fakePicker 把以 "remote" 开头的 key 分配给 owner，其余属于本节点
+type fakePicker struct {
+	owner *fakePeer
+	all   []*fakePeer
+}
+
+func (p *fakePicker) Pick(key string) (Fetcher, bool) {
+	if len(key) >= 6 && key[:6] == "remote" {
+		return p.owner, true
+	}
+	return nil, false
+}
+
+func (p *fakePicker) Peers() []Fetcher {
+	peers := make([]Fetcher, 0, len(p.all))
+	for _, peer := range p.all {
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// 测试 Set/Remove/Invalidate 被路由到正确的节点
+func TestGroupSetRemoveInvalidate(t *testing.T) {
+	owner := &fakePeer{values: map[string][]byte{}}
+	other := &fakePeer{values: map[string][]byte{}}
+	g := NewGroup("writes", 1<<10, time.Minute, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("db"), nil
+	}))
+	g.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner, other}})
+
+	// 本节点的 key 直接写入 mainCache
+	if err := g.Set("local", []byte("v1"), 0); err != nil {
+		t.Fatalf("Set local failed: %v", err)
+	}
+	if v, ok := g.mainCache.get("local"); !ok || v.String() != "v1" {
+		t.Fatalf("local key should be set in mainCache, got %q", v.String())
+	}
+
+	// 远端节点的 key 写入 owner，本地不保留
+	g.mainCache.add("remote1", ByteView{b: []byte("stale")}, time.Minute)
+	if err := g.Set("remote1", []byte("v2"), time.Second); err != nil {
+		t.Fatalf("Set remote failed: %v", err)
+	}
+	if string(owner.values["remote1"]) != "v2" {
+		t.Fatalf("remote key should be set on owner, got %q", owner.values["remote1"])
+	}
+	if _, ok := g.mainCache.get("remote1"); ok {
+		t.Fatalf("stale local copy of remote1 should be dropped")
+	}
+	if v, err := g.Get("remote1"); err != nil || v.String() != "v2" {
+		t.Fatalf("Get remote1 should read from owner, got %q %v", v.String(), err)
+	}
+
+	if err := g.Remove("remote1"); err != nil {
+		t.Fatalf("Remove remote failed: %v", err)
+	}
+	if _, ok := owner.values["remote1"]; ok {
+		t.Fatalf("remote1 should be removed from owner")
+	}
+	if err := g.Remove("local"); err != nil {
+		t.Fatalf("Remove local failed: %v", err)
+	}
+	if _, ok := g.mainCache.get("local"); ok {
+		t.Fatalf("local should be removed from mainCache")
+	}
+
+	g.mainCache.add("shared", ByteView{b: []byte("v3")}, time.Minute)
+	if err := g.Invalidate("shared"); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	if _, ok := g.mainCache.get("shared"); ok {
+		t.Fatalf("shared should be invalidated locally")
+	}
+	if len(owner.invalidated) != 1 || len(other.invalidated) != 1 {
+		t.Fatalf("Invalidate should reach every peer, got %v %v", owner.invalidated, other.invalidated)
+	}
+}
+
+// replicaPicker 在 fakePicker 的基础上把写操作发送给 all 中的每个节点，self 表示本节点也是副本
+type replicaPicker struct {
+	fakePicker
+	self bool
+}
+
+func (p *replicaPicker) PickReplicas(key string) ([]Fetcher, bool) {
+	return p.Peers(), p.self
+}
+
+// 测试开启副本写入时 Set/Remove 发送给每个副本
+func TestGroupReplicatedWrites(t *testing.T) {
+	owner := &fakePeer{values: map[string][]byte{}}
+	replica := &fakePeer{values: map[string][]byte{}}
+	picker := &replicaPicker{fakePicker: fakePicker{owner: owner, all: []*fakePeer{owner, replica}}}
+	g := NewGroup("replicatedWrites", 1<<10, time.Minute, GetterFunc(mockGetter))
+	g.RegisterPeers(picker)
+
+	g.mainCache.add("remote1", ByteView{b: []byte("stale")}, time.Minute)
+	if err := g.Set("remote1", []byte("v1"), 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if string(owner.values["remote1"]) != "v1" || string(replica.values["remote1"]) != "v1" {
+		t.Fatalf("Set should reach every replica, got %q %q", owner.values["remote1"], replica.values["remote1"])
+	}
+	if _, ok := g.mainCache.get("remote1"); ok {
+		t.Fatalf("stale local copy should be dropped when this node is not a replica")
+	}
+
+	picker.self = true
+	if err := g.Set("remote2", []byte("v2"), 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if v, ok := g.mainCache.get("remote2"); !ok || v.String() != "v2" || string(replica.values["remote2"]) != "v2" {
+		t.Fatalf("Set should also be applied locally when this node is a replica")
+	}
+
+	if err := g.Remove("remote2"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, ok := owner.values["remote2"]; ok {
+		t.Fatalf("Remove should reach the owner")
+	}
+	if _, ok := replica.values["remote2"]; ok {
+		t.Fatalf("Remove should reach every replica")
+	}
+	if _, ok := g.mainCache.get("remote2"); ok {
+		t.Fatalf("Remove should drop the local replica")
+	}
+}
+
+// 测试调用方的 deadline 传递到 GetterWithContext
+func TestGetContextDeadline(t *testing.T) {
+	g := NewGroup("ctxGroup", 1<<10, time.Minute, GetterWithContextFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			if _, ok := ctx.Deadline(); !ok {
+				return nil, fmt.Errorf("deadline of %s is lost", key)
+			}
+			return []byte(key), nil
+		}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if v, err := g.GetContext(ctx, "Tom"); err != nil || v.String() != "Tom" {
+		t.Fatalf("GetContext failed: %q %v", v.String(), err)
+	}
+	// 没有 deadline 的 Get 也会调用 GetterWithContext，loader 收到的 ctx 没有 deadline，因此返回错误
+	if _, err := g.Get("Jack"); err == nil {
+		t.Fatalf("Get without deadline should fail because RetrieveContext sees no deadline")
+	}
+}
+
+// 测试 ctx 结束后 GetContext 立即返回，且加载函数收到取消信号
+func TestGetContextCancel(t *testing.T) {
+	canceled := make(chan struct{})
+	g := NewGroup("slowGroup", 1<<10, time.Minute, GetterWithContextFunc(
+		func(ctx context.Context, key string) ([]byte, error) {
+			<-ctx.Done()
+			close(canceled)
+			return nil, ctx.Err()
+		}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := g.GetContext(ctx, "slow"); err != context.DeadlineExceeded {
+		t.Fatalf("GetContext should fail with deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("GetContext should return once the deadline passes")
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatalf("the loader should observe the cancellation")
+	}
+}
+
+// batchLoader 记录 RetrieveBatch 被调用时收到的 key
+type batchLoader struct {
+	db      map[string]string
+	batches [][]string
+}
+
+func (l *batchLoader) Retrieve(key string) ([]byte, error) {
+	return nil, fmt.Errorf("GetMulti should use RetrieveBatch")
+}
+
+func (l *batchLoader) RetrieveBatch(ctx context.Context, keys []string) (map[string][]byte, error) {
+	l.batches = append(l.batches, keys)
+	values := make(map[string][]byte)
+	for _, key := range keys {
+		if v, ok := l.db[key]; ok {
+			values[key] = []byte(v)
+		}
+	}
+	return values, nil
+}
+
+// 测试 GetMulti 按节点分组：远端 key 一次批量请求，本地未命中的 key 一次批量加载
+func TestGetMulti(t *testing.T) {
+	owner := &fakePeer{values: map[string][]byte{"remote1": []byte("r1"), "remote2": []byte("r2")}}
+	loader := &batchLoader{db: map[string]string{"Tom": "630", "Jack": "589"}}
+	g := NewGroup("multi", 1<<10, time.Minute, loader)
+	g.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner}})
+	g.mainCache.add("Sam", ByteView{b: []byte("567")}, time.Minute)
+
+	keys := []string{"Tom", "Jack", "Sam", "Lily", "remote1", "remote2", "remote3", "Tom", ""}
+	values, errs := g.GetMulti(context.Background(), keys)
+
+	expected := map[string]string{"Tom": "630", "Jack": "589", "Sam": "567", "remote1": "r1", "remote2": "r2"}
+	if len(values) != len(expected) {
+		t.Fatalf("expected %d values, got %d: %v", len(expected), len(values), values)
+	}
+	for key, v := range expected {
+		if values[key].String() != v {
+			t.Fatalf("value of %s should be %s, got %s", key, v, values[key].String())
+		}
+	}
+	var nf *NotFoundError
+	if !errors.As(errs["Lily"], &nf) {
+		t.Fatalf("Lily should be not found, got %v", errs["Lily"])
+	}
+	if errs["remote3"] == nil || errs[""] == nil {
+		t.Fatalf("remote3 and empty key should fail, got %v", errs)
+	}
+	if owner.batches != 1 {
+		t.Fatalf("remote keys should be fetched in 1 batch, got %d", owner.batches)
+	}
+	if len(loader.batches) != 1 || len(loader.batches[0]) != 3 {
+		t.Fatalf("local misses should be loaded in 1 batch of 3 keys, got %v", loader.batches)
+	}
+	if _, ok := g.mainCache.get("Tom"); !ok {
+		t.Fatalf("batch loaded values should populate mainCache")
+	}
+}
+
+// expiryBatchLoader 批量加载时为每个 key 返回过期时刻
+type expiryBatchLoader struct {
+	batchLoader
+	expires map[string]time.Time
+}
+
+func (l *expiryBatchLoader) RetrieveBatchExpiry(ctx context.Context, keys []string) (map[string][]byte, map[string]time.Time, error) {
+	values, err := l.RetrieveBatch(ctx, keys)
+	return values, l.expires, err
+}
+
+// 测试 BatchExpiryGetter 返回的过期时刻覆盖 Group 的 Expire
+func TestGetMultiExpiry(t *testing.T) {
+	expire := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	loader := &expiryBatchLoader{
+		batchLoader: batchLoader{db: map[string]string{"Tom": "630", "Jack": "589"}},
+		expires:     map[string]time.Time{"Tom": expire},
+	}
+	g := NewGroup("multiExpiry", 1<<10, time.Minute, loader)
+
+	values, errs := g.GetMulti(context.Background(), []string{"Tom", "Jack"})
+	if len(values) != 2 || len(errs) != 0 {
+		t.Fatalf("GetMulti failed: %v %v", values, errs)
+	}
+	if !values["Tom"].Expire().Equal(expire) {
+		t.Fatalf("Tom should expire at %v, got %v", expire, values["Tom"].Expire())
+	}
+	if d := time.Until(values["Jack"].Expire()); d <= 0 || d > time.Minute {
+		t.Fatalf("Jack without expiry should use the Group Expire, got %v", d)
+	}
+	if v, ok := g.mainCache.get("Tom"); !ok || !v.Expire().Equal(expire) {
+		t.Fatalf("mainCache should keep the expiry of Tom")
+	}
+}
+
+// slowBatchLoader 的 Retrieve 阻塞到 release 关闭，用于构造与 GetMulti 并发的 Get
+type slowBatchLoader struct {
+	batchLoader
+	mu      sync.Mutex
+	loads   int
+	started chan struct{}
+	release chan struct{}
+}
+
+func (l *slowBatchLoader) Retrieve(key string) ([]byte, error) {
+	l.mu.Lock()
+	l.loads++
+	l.mu.Unlock()
+	close(l.started)
+	<-l.release
+	return []byte(l.db[key]), nil
+}
+
+func (l *slowBatchLoader) RetrieveBatch(ctx context.Context, keys []string) (map[string][]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.batchLoader.RetrieveBatch(ctx, keys)
+}
+
+// 测试 GetMulti 的批量加载不会重复加载正被 Get 加载的 key
+func TestGetMultiJoinsGet(t *testing.T) {
+	loader := &slowBatchLoader{
+		batchLoader: batchLoader{db: map[string]string{"Tom": "630", "Jack": "589"}},
+		started:     make(chan struct{}),
+		release:     make(chan struct{}),
+	}
+	g := NewGroup("multiJoin", 1<<10, time.Minute, loader)
+
+	got := make(chan ByteView, 1)
+	go func() {
+		v, _ := g.Get("Tom")
+		got <- v
+	}()
+	<-loader.started
+
+	done := make(chan struct{})
+	var values map[string]ByteView
+	go func() {
+		defer close(done)
+		values, _ = g.GetMulti(context.Background(), []string{"Tom", "Jack"})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(loader.release)
+	<-done
+
+	if values["Tom"].String() != "630" || values["Jack"].String() != "589" || (<-got).String() != "630" {
+		t.Fatalf("unexpected values %v", values)
+	}
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+	if loader.loads != 1 || len(loader.batches) != 1 || len(loader.batches[0]) != 1 || loader.batches[0][0] != "Jack" {
+		t.Fatalf("Tom should be loaded once by Get and only Jack by the batch, got %d loads and batches %v", loader.loads, loader.batches)
+	}
+}
+
+// 测试从远端获取的值带有远端的过期时间
+func TestGetRemoteExpire(t *testing.T) {
+	expire := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	owner := &fakePeer{
+		values:  map[string][]byte{"remoteA": []byte("a"), "remoteB": []byte("b")},
+		expires: map[string]time.Time{"remoteA": expire},
+	}
+	g := NewGroup("remoteExpire", 1<<10, time.Minute, GetterFunc(mockGetter))
+	g.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner}})
+
+	if v, err := g.Get("remoteA"); err != nil || !v.Expire().Equal(expire) {
+		t.Fatalf("remoteA should expire at %v, got %v %v", expire, v.Expire(), err)
+	}
+	values, errs := g.GetMulti(context.Background(), []string{"remoteA", "remoteB"})
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	if !values["remoteA"].Expire().Equal(expire) || !values["remoteB"].Expire().IsZero() {
+		t.Fatalf("GetMulti lost the remote expiry: %v %v", values["remoteA"].Expire(), values["remoteB"].Expire())
+	}
+}
+
+// 测试远端节点返回 ErrNotFound 时不回退到本地加载
+func TestGetRemoteNotFound(t *testing.T) {
+	loads := 0
+	g := NewGroup("remoteNotFound", 1<<10, time.Minute, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte(key), nil
+	}))
+	g.RegisterPeers(notFoundPeer{})
+
+	if _, err := g.Get("remoteA"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("remote miss should yield ErrNotFound, got %v", err)
+	}
+	if loads != 0 {
+		t.Fatalf("remote ErrNotFound should not fall back to the local getter")
+	}
+}
+
+// notFoundPeer 拥有所有 key，并对所有 key 返回 *NotFoundError
+type notFoundPeer struct{}
+
+func (p notFoundPeer) Pick(key string) (Fetcher, bool) {
+	return p, true
+}
+
+func (notFoundPeer) Fetch(group string, key string) ([]byte, error) {
+	return nil, &NotFoundError{Group: group, Key: key}
+}
+
+// versionGetter 每次加载返回递增的版本号，release 关闭前加载会阻塞
+type versionGetter struct {
+	loads   int32
+	release chan struct{}
+}
+
+func (l *versionGetter) Retrieve(key string) ([]byte, error) {
+	n := atomic.AddInt32(&l.loads, 1)
+	if n > 1 {
+		<-l.release
+	}
+	return []byte(fmt.Sprintf("%s_v%d", key, n)), nil
+}
+
+// waitFor 轮询直到 cond 成立或超时
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met within 1s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// 测试软过期后返回旧值并只在后台刷新一次
+func TestStaleWhileRevalidate(t *testing.T) {
+	l := &versionGetter{release: make(chan struct{})}
+	g := NewGroup("staleGroup", 1<<10, time.Minute, l, WithStaleWhileRevalidate(30*time.Millisecond, time.Second))
+	if v, err := g.Get("Tom"); err != nil || v.String() != "Tom_v1" {
+		t.Fatalf("first Get failed: %q %v", v.String(), err)
+	}
+	time.Sleep(40 * time.Millisecond)
+
+	// 刷新被阻塞时，调用方仍立即得到旧值
+	for i := 0; i < 5; i++ {
+		if v, err := g.Get("Tom"); err != nil || v.String() != "Tom_v1" {
+			t.Fatalf("stale value should be served during refresh, got %q %v", v.String(), err)
+		}
+	}
+	close(l.release)
+	waitFor(t, func() bool {
+		v, _ := g.mainCache.get("Tom")
+		return v.String() == "Tom_v2"
+	})
+	if n := atomic.LoadInt32(&l.loads); n != 2 {
+		t.Fatalf("expected a single background refresh, got %d loads", n)
+	}
+}
+
+// 测试快过期的热点 key 被提前刷新
+func TestRefreshAhead(t *testing.T) {
+	l := &versionGetter{release: make(chan struct{})}
+	close(l.release)
+	g := NewGroup("refreshAheadGroup", 1<<10, 100*time.Millisecond, l, WithRefreshAhead(80*time.Millisecond))
+	g.Get("Tom")
+	time.Sleep(30 * time.Millisecond)
+
+	if v, err := g.Get("Tom"); err != nil || v.String() != "Tom_v1" {
+		t.Fatalf("Get should return the cached value, got %q %v", v.String(), err)
+	}
+	waitFor(t, func() bool {
+		v, _ := g.mainCache.get("Tom")
+		return v.String() == "Tom_v2"
+	})
+	if v, _ := g.mainCache.get("Tom"); time.Until(v.Expire()) < 80*time.Millisecond {
+		t.Fatalf("refreshed value should get a new expiry, got %v", v.Expire())
+	}
+}
+
+// 测试从远端获取的值被写入 hotCache，并单独统计
+func TestHotCache(t *testing.T) {
+	owner := &fakePeer{values: map[string][]byte{"remoteA": []byte("a"), "remoteB": []byte("b")}}
+	g := NewGroup("hotGroup", 1<<10, time.Minute, GetterFunc(mockGetter), WithHotCache(1<<10, 50*time.Millisecond, 1))
+	g.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner}})
+
+	for i := 0; i < 3; i++ {
+		if v, err := g.Get("remoteA"); err != nil || v.String() != "a" {
+			t.Fatalf("Get remoteA failed: %q %v", v.String(), err)
+		}
+	}
+	if owner.fetches != 1 {
+		t.Fatalf("hot key should be fetched from the owner once, got %d", owner.fetches)
+	}
+	hot, main := g.CacheStats(HotCache), g.CacheStats(MainCache)
+	if hot.Items != 1 || hot.Hits != 2 || hot.Bytes != int64(len("remoteA")+1) {
+		t.Fatalf("unexpected hotCache stats %+v", hot)
+	}
+	if main.Items != 0 || main.Hits != 0 {
+		t.Fatalf("peer-fetched values should not enter mainCache, got %+v", main)
+	}
+
+	// 副本不超过 hotTTL
+	time.Sleep(60 * time.Millisecond)
+	g.Get("remoteA")
+	if owner.fetches != 2 {
+		t.Fatalf("hot copy should expire after the hot ttl, got %d fetches", owner.fetches)
+	}
+
+	// Invalidate 同时丢弃 hotCache 中的副本
+	if err := g.Invalidate("remoteA"); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	g.Get("remoteA")
+	if owner.fetches != 3 {
+		t.Fatalf("invalidated hot copy should be refetched, got %d fetches", owner.fetches)
+	}
+
+	// 未启用 hotCache 时每次都访问远端节点
+	g = NewGroup("coldGroup", 1<<10, time.Minute, GetterFunc(mockGetter))
+	g.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner}})
+	g.Get("remoteB")
+	g.Get("remoteB")
+	if owner.fetches != 5 {
+		t.Fatalf("without hotCache every Get should reach the owner, got %d fetches", owner.fetches)
+	}
+}
+
+// 测试 DestroyGroup 与并发的 Groups 遍历，需配合 -race 运行
+func TestDestroyGroupConcurrentGroups(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			Groups()
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("destroy%d", i)
+		svr, err := NewServer("localhost:9978", WithRegistry(registry.NewMemoryRegistry()), WithServerLogger(NopLogger))
+		if err != nil {
+			t.Fatalf("Failed to create server: %v", err)
+		}
+		g := NewGroup(name, 1<<10, time.Minute, GetterFunc(mockGetter), WithLogger(NopLogger))
+		g.RegisterPeers(svr)
+		DestroyGroup(name)
+		if GetGroup(name) != nil {
+			t.Fatalf("%s should be destroyed", name)
+		}
+	}
+	<-done
+}
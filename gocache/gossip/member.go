@@ -0,0 +1,67 @@
+package gossip
+
+import (
+	"fmt"
+	"gocache/registry"
+)
+
+// member模块定义节点及其状态，以及状态之间的覆盖规则
+
+// State 是节点在本地视图中的状态
+type State int
+
+const (
+	StateAlive   State = iota // 正常
+	StateSuspect              // 探测失败，等待节点自己反驳
+	StateDead                 // 怀疑超时或主动离开，不再属于集群
+)
+
+func (s State) String() string {
+	switch s {
+	case StateAlive:
+		return "alive"
+	case StateSuspect:
+		return "suspect"
+	case StateDead:
+		return "dead"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Node 描述集群中的一个节点
+type Node struct {
+	Name   string `json:"n"`           // gocache 服务的地址，也是节点的唯一标识
+	Addr   string `json:"a"`           // gossip 使用的 UDP 地址
+	Weight int    `json:"w,omitempty"` // 容量权重
+	Zone   string `json:"z,omitempty"` // 可用区
+
+	Version  string   `json:"v,omitempty"` // 构建版本
+	Features []string `json:"f,omitempty"` // 支持的 RPC 功能
+}
+
+// Metadata 返回节点的元数据
+func (n Node) Metadata() registry.Metadata {
+	return registry.Metadata{Weight: n.Weight, Zone: n.Zone, Version: n.Version, Features: n.Features}
+}
+
+// Member 是一个节点的状态，Incarnation 只能由节点自己增加，用来反驳其他节点对它的怀疑
+type Member struct {
+	Node
+	State       State  `json:"s"`
+	Incarnation uint64 `json:"i"`
+}
+
+// supersedes 判断关于同一节点的消息 n 是否比本地的 o 更新
+// 相同 Incarnation 下 dead 覆盖 suspect，suspect 覆盖 alive；
+// 只有 Incarnation 更大的 alive 才能覆盖 suspect 与 dead
+func supersedes(n, o Member) bool {
+	switch n.State {
+	case StateAlive:
+		return n.Incarnation > o.Incarnation
+	case StateSuspect:
+		return o.State == StateAlive && n.Incarnation >= o.Incarnation ||
+			o.State == StateSuspect && n.Incarnation > o.Incarnation
+	default:
+		return o.State != StateDead && n.Incarnation >= o.Incarnation
+	}
+}
@@ -0,0 +1,812 @@
+// Package gossip 实现 SWIM 风格的成员管理与故障检测，不依赖外部的协调服务
+//
+// 每个节点定期随机探测一个成员，探测失败时请其他成员代为探测，仍然失败则将其标记为 suspect；
+// suspect 的节点在 SuspicionTimeout 内没有反驳就被标记为 dead。
+// 成员变化捎带在 UDP 探测消息中传播；新节点通过 TCP 与种子节点交换全部成员(push-pull)加入集群，
+// 之后每隔 PushPullInterval 与一个随机成员再同步一次，修复丢失的成员变化。
+// 成员被标记为 dead 后在本地视图中保留 DeadReapTimeout，之后被删除。
+// Memberlist 实现了 registry.Registry，可以通过 gocache.WithRegistry 与 gocache.WithPeerWatch 维护哈希环。
+package gossip
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gocache/registry"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProbeInterval    = time.Second
+	defaultProbeTimeout     = 500 * time.Millisecond
+	defaultIndirectChecks   = 3
+	defaultSuspicionTimeout = 5 * time.Second
+	defaultRetransmitMult   = 4
+	defaultPushPullInterval = 30 * time.Second
+	defaultDeadReapTimeout  = 30 * time.Second
+	defaultTCPTimeout       = 10 * time.Second
+	maxPiggyback            = 16    // 每个消息最多捎带的成员变化
+	maxPacketSize           = 65507 // UDP 包的最大长度，消息最多捎带 maxPiggyback 个成员变化，全部成员通过 TCP 同步
+)
+
+// Config 是 Memberlist 的配置
+type Config struct {
+	// Name 为节点的唯一标识，应与 gocache 服务的地址相同；为空时使用 Register 传入的 addr
+	Name string
+	// BindAddr 为监听的地址，例如 0.0.0.0:7946，UDP 与 TCP 使用同一个端口，端口为 0 时随机选择
+	BindAddr string
+	// AdvertiseAddr 为其他节点访问本节点使用的地址，为空时使用实际监听的地址
+	AdvertiseAddr string
+	// Seeds 为加入集群时联系的种子节点的地址
+	Seeds []string
+	// Weight、Zone、Version 与 Features 随成员信息传播，Weight 为 0 时为 1
+	// RegisterInstance 传入的元数据中不为零值的字段会覆盖这里的配置
+	Weight   int
+	Zone     string
+	Version  string
+	Features []string
+
+	ProbeInterval    time.Duration // 探测的间隔，默认 1 秒
+	ProbeTimeout     time.Duration // 等待直接探测回复的时间，默认 500 毫秒
+	IndirectChecks   int           // 直接探测失败后请多少个成员代为探测，默认 3
+	SuspicionTimeout time.Duration // suspect 的节点被标记为 dead 前等待的时间，默认 5 秒
+	RetransmitMult   int           // 每个成员变化捎带发送 RetransmitMult*log10(n+1) 次，默认 4
+	PushPullInterval time.Duration // 通过 TCP 与随机成员同步全部成员的间隔，默认 30 秒
+	DeadReapTimeout  time.Duration // dead 的成员在本地视图中保留的时间，默认 30 秒，应足够让 dead 传播到整个集群
+	TCPTimeout       time.Duration // 一次 TCP 同步的超时时间，默认 10 秒
+
+	Logger registry.Logger // 为 nil 时使用标准库 log
+}
+
+// Memberlist 维护本节点看到的集群成员
+type Memberlist struct {
+	cfg    Config
+	conn   net.PacketConn // 探测与捎带成员变化
+	ln     net.Listener   // 全部成员的 push-pull 同步
+	logger registry.Logger
+
+	mu         sync.Mutex
+	self       Member
+	joined     bool               // Register 之后为 true
+	left       bool               // Deregister 之后为 true
+	members    map[string]*member // 其他节点，key 为 Name
+	broadcasts []*broadcast       // 等待捎带发送的成员变化
+	acks       map[uint64]chan struct{}
+	seq        uint64
+	probeOrder []string // 本轮探测的顺序
+	watchers   map[chan struct{}]bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// member 是本地视图中的其他节点
+type member struct {
+	Member
+	suspectTimer *time.Timer
+	deadAt       time.Time // 被标记为 dead 的时刻，超过 DeadReapTimeout 后删除
+}
+
+// stdLogger 使用标准库 log 输出日志
+type stdLogger struct{}
+
+func (stdLogger) Info(msg string, args ...interface{}) {
+	log.Println(append([]interface{}{msg}, args...)...)
+}
+
+func (stdLogger) Error(msg string, args ...interface{}) {
+	log.Println(append([]interface{}{msg}, args...)...)
+}
+
+// Create 开始在 cfg.BindAddr 上监听，节点在 Register 之后才会加入集群
+func Create(cfg Config) (*Memberlist, error) {
+	if cfg.ProbeInterval <= 0 {
+		cfg.ProbeInterval = defaultProbeInterval
+	}
+	if cfg.ProbeTimeout <= 0 || cfg.ProbeTimeout >= cfg.ProbeInterval {
+		cfg.ProbeTimeout = cfg.ProbeInterval / 2
+	}
+	if cfg.IndirectChecks <= 0 {
+		cfg.IndirectChecks = defaultIndirectChecks
+	}
+	if cfg.SuspicionTimeout <= 0 {
+		cfg.SuspicionTimeout = defaultSuspicionTimeout
+	}
+	if cfg.RetransmitMult <= 0 {
+		cfg.RetransmitMult = defaultRetransmitMult
+	}
+	if cfg.PushPullInterval <= 0 {
+		cfg.PushPullInterval = defaultPushPullInterval
+	}
+	if cfg.DeadReapTimeout <= 0 {
+		cfg.DeadReapTimeout = defaultDeadReapTimeout
+	}
+	if cfg.TCPTimeout <= 0 {
+		cfg.TCPTimeout = defaultTCPTimeout
+	}
+	if cfg.Weight <= 0 {
+		cfg.Weight = 1
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = stdLogger{}
+	}
+
+	ln, conn, err := listen(cfg.BindAddr)
+	if err != nil {
+		return nil, fmt.Errorf("listen %s failed: %v", cfg.BindAddr, err)
+	}
+	addr := cfg.AdvertiseAddr
+	if addr == "" {
+		addr = conn.LocalAddr().String()
+	}
+	m := &Memberlist{
+		cfg:    cfg,
+		conn:   conn,
+		ln:     ln,
+		logger: cfg.Logger,
+		self: Member{
+			Node: Node{Name: cfg.Name, Addr: addr, Weight: cfg.Weight, Zone: cfg.Zone, Version: cfg.Version, Features: cfg.Features},
+		},
+		members:  make(map[string]*member),
+		acks:     make(map[uint64]chan struct{}),
+		watchers: make(map[chan struct{}]bool),
+		done:     make(chan struct{}),
+	}
+	go m.readLoop()
+	go m.acceptLoop()
+	go m.probeLoop()
+	go m.pushPullLoop()
+	return m, nil
+}
+
+// listen 在同一个端口上监听 TCP 与 UDP，端口为 0 时重试直到选出两者都可用的端口
+func listen(bindAddr string) (net.Listener, net.PacketConn, error) {
+	_, port, err := net.SplitHostPort(bindAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := 0; ; i++ {
+		ln, err := net.Listen("tcp", bindAddr)
+		if err != nil {
+			return nil, nil, err
+		}
+		conn, err := net.ListenPacket("udp", ln.Addr().String())
+		if err == nil {
+			return ln, conn, nil
+		}
+		ln.Close()
+		if port != "0" || i >= 10 {
+			return nil, nil, err
+		}
+	}
+}
+
+// Addr 返回本节点的 gossip 地址，其他节点可以将它作为种子
+func (m *Memberlist) Addr() string {
+	return m.self.Addr
+}
+
+// Members 返回本节点看到的 alive 与 suspect 的成员，包括自身，按 Name 排序
+func (m *Memberlist) Members() []Member {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	members := make([]Member, 0, len(m.members)+1)
+	if m.joined && !m.left {
+		members = append(members, m.self)
+	}
+	for _, mb := range m.members {
+		if mb.State != StateDead {
+			members = append(members, mb.Member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	return members
+}
+
+// Register 以 addr 为 Name(Config.Name 为空时)加入集群，并联系种子节点
+// 种子节点暂时无法访问时不返回错误，探测循环会在没有其他成员时继续联系种子节点
+func (m *Memberlist) Register(ctx context.Context, service string, addr string) error {
+	return m.RegisterInstance(ctx, service, registry.Instance{Addr: addr})
+}
+
+// RegisterInstance 与 Register 相同，但以 ins.Metadata 中不为零值的字段更新本节点的元数据
+// 已经加入集群时再次调用只会传播新的元数据
+func (m *Memberlist) RegisterInstance(ctx context.Context, service string, ins registry.Instance) error {
+	m.mu.Lock()
+	if m.self.Name == "" {
+		m.self.Name = ins.Addr
+	}
+	node := m.self.Node
+	if md := ins.Metadata; md.Weight > 0 {
+		node.Weight = md.Weight
+	}
+	if md := ins.Metadata; md.Zone != "" {
+		node.Zone = md.Zone
+	}
+	if md := ins.Metadata; md.Version != "" {
+		node.Version = md.Version
+	}
+	if md := ins.Metadata; md.Features != nil {
+		node.Features = md.Features
+	}
+	rejoin := m.joined && !m.left
+	updated := !reflect.DeepEqual(node, m.self.Node)
+	m.self.Node = node
+	if m.left || rejoin && updated {
+		// 重新加入或元数据变化时增大 Incarnation，覆盖其他节点记录的 dead 或旧的元数据
+		m.self.Incarnation++
+	}
+	m.joined, m.left = true, false
+	m.self.State = StateAlive
+	m.queue(m.self)
+	m.mu.Unlock()
+	m.notify()
+
+	if rejoin {
+		return nil
+	}
+	if !m.join(ctx) && len(m.cfg.Seeds) > 0 {
+		m.logger.Error("[gossip] no seed answered, retry later", "name", m.self.Name, "seeds", m.cfg.Seeds)
+	}
+	return nil
+}
+
+// Deregister 通知其他成员本节点离开集群
+func (m *Memberlist) Deregister(ctx context.Context, service string, addr string) error {
+	m.mu.Lock()
+	if !m.joined || m.left {
+		m.mu.Unlock()
+		return nil
+	}
+	m.left = true
+	m.self.State = StateDead
+	msg := message{Type: msgGossip, Updates: []Member{m.self}}
+	var addrs []string
+	for _, mb := range m.members {
+		if mb.State != StateDead {
+			addrs = append(addrs, mb.Addr)
+		}
+	}
+	m.mu.Unlock()
+	m.notify()
+
+	for _, addr := range addrs {
+		m.sendRaw(addr, msg)
+	}
+	return nil
+}
+
+// Watch 以 alive 与 suspect 的成员的 Name 列表调用 onChange，service 被忽略
+func (m *Memberlist) Watch(ctx context.Context, service string, onChange func(addrs []string)) error {
+	var last []string
+	return m.WatchInstances(ctx, service, func(instances []registry.Instance) {
+		addrs := make([]string, 0, len(instances))
+		for _, ins := range instances {
+			addrs = append(addrs, ins.Addr)
+		}
+		if last == nil || !equal(addrs, last) {
+			last = addrs
+			onChange(addrs)
+		}
+	})
+}
+
+// WatchInstances 以 alive 与 suspect 的成员及其元数据调用 onChange，成员或元数据变化时再次调用
+func (m *Memberlist) WatchInstances(ctx context.Context, service string, onChange func(instances []registry.Instance)) error {
+	ch := make(chan struct{}, 1)
+	m.mu.Lock()
+	m.watchers[ch] = true
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.watchers, ch)
+		m.mu.Unlock()
+	}()
+
+	var last []registry.Instance
+	for first := true; ; first = false {
+		instances, _ := m.ResolveInstances(ctx, service)
+		if first || !reflect.DeepEqual(instances, last) {
+			last = instances
+			onChange(instances)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-m.done:
+			return nil
+		case <-ch:
+		}
+	}
+}
+
+// Resolve 返回 alive 与 suspect 的成员的 Name，service 被忽略
+func (m *Memberlist) Resolve(ctx context.Context, service string) ([]string, error) {
+	members := m.Members()
+	addrs := make([]string, 0, len(members))
+	for _, mb := range members {
+		addrs = append(addrs, mb.Name)
+	}
+	return addrs, nil
+}
+
+// ResolveInstances 返回 alive 与 suspect 的成员及其元数据，service 被忽略
+func (m *Memberlist) ResolveInstances(ctx context.Context, service string) ([]registry.Instance, error) {
+	members := m.Members()
+	instances := make([]registry.Instance, 0, len(members))
+	for _, mb := range members {
+		instances = append(instances, registry.Instance{Addr: mb.Name, Metadata: mb.Metadata()})
+	}
+	return instances, nil
+}
+
+// Close 停止探测并关闭 UDP 连接与 TCP 监听，不会通知其他成员，需要时先调用 Deregister
+func (m *Memberlist) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		close(m.done)
+		err = errors.Join(m.conn.Close(), m.ln.Close())
+		m.mu.Lock()
+		for _, mb := range m.members {
+			if mb.suspectTimer != nil {
+				mb.suspectTimer.Stop()
+			}
+		}
+		m.mu.Unlock()
+	})
+	return err
+}
+
+// join 通过 TCP 与所有种子节点交换全部成员，返回是否有种子节点完成了同步
+func (m *Memberlist) join(ctx context.Context) bool {
+	joined := false
+	for _, seed := range m.cfg.Seeds {
+		if seed == m.self.Addr {
+			continue
+		}
+		if err := m.pushPull(ctx, seed); err != nil {
+			m.logger.Error("[gossip] sync with seed failed", "seed", seed, "err", err)
+			continue
+		}
+		joined = true
+	}
+	return joined
+}
+
+// pushPull 通过 TCP 把本节点看到的全部成员发送给 addr，并合并对方回复的全部成员
+// 成员数不受 UDP 包大小的限制
+func (m *Memberlist) pushPull(ctx context.Context, addr string) error {
+	d := net.Dialer{Timeout: m.cfg.TCPTimeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(m.cfg.TCPTimeout))
+	if err := json.NewEncoder(conn).Encode(m.snapshot()); err != nil {
+		return err
+	}
+	var remote []Member
+	if err := json.NewDecoder(conn).Decode(&remote); err != nil {
+		return err
+	}
+	m.merge(remote)
+	return nil
+}
+
+// acceptLoop 处理其他节点发起的 push-pull 直到 Close
+func (m *Memberlist) acceptLoop() {
+	for {
+		conn, err := m.ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		go m.handleStream(conn)
+	}
+}
+
+// handleStream 读取对方的全部成员，回复本节点的全部成员后再合并
+func (m *Memberlist) handleStream(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(m.cfg.TCPTimeout))
+	var remote []Member
+	if err := json.NewDecoder(conn).Decode(&remote); err != nil {
+		m.logger.Error("[gossip] invalid push-pull", "from", conn.RemoteAddr().String(), "err", err)
+		return
+	}
+	if err := json.NewEncoder(conn).Encode(m.snapshot()); err != nil {
+		m.logger.Error("[gossip] reply push-pull failed", "from", conn.RemoteAddr().String(), "err", err)
+	}
+	m.merge(remote)
+}
+
+// pushPullLoop 每隔 PushPullInterval 与一个随机的 alive 成员同步全部成员
+func (m *Memberlist) pushPullLoop() {
+	ticker := time.NewTicker(m.cfg.PushPullInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+		m.mu.Lock()
+		active := m.joined && !m.left
+		m.mu.Unlock()
+		if !active {
+			continue
+		}
+		for _, mb := range m.randomMembers(1, "") {
+			if err := m.pushPull(context.Background(), mb.Addr); err != nil {
+				m.logger.Error("[gossip] push-pull failed", "name", mb.Name, "err", err)
+			}
+		}
+	}
+}
+
+// readLoop 处理收到的消息直到 Close
+func (m *Memberlist) readLoop() {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, from, err := m.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal(buf[:n], &msg); err != nil {
+			m.logger.Error("[gossip] invalid message", "from", from.String(), "err", err)
+			continue
+		}
+		m.handle(msg, from.String())
+	}
+}
+
+// handle 合并消息中的成员变化，并回复探测
+func (m *Memberlist) handle(msg message, from string) {
+	m.merge(msg.Updates)
+	switch msg.Type {
+	case msgPing:
+		m.send(from, message{Type: msgAck, Seq: msg.Seq})
+	case msgAck:
+		m.mu.Lock()
+		ch := m.acks[msg.Seq]
+		m.mu.Unlock()
+		if ch != nil {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	case msgPingReq:
+		go m.relay(msg, from)
+	}
+}
+
+// relay 代 from 探测 msg.Target，收到回复后以 from 的 Seq 回复 from
+func (m *Memberlist) relay(msg message, from string) {
+	seq, ch := m.waitAck()
+	defer m.dropAck(seq)
+	m.send(msg.Target, message{Type: msgPing, Seq: seq})
+	select {
+	case <-ch:
+		m.send(from, message{Type: msgAck, Seq: msg.Seq})
+	case <-time.After(m.cfg.ProbeTimeout):
+	case <-m.done:
+	}
+}
+
+// probeLoop 每隔 ProbeInterval 探测一个成员
+func (m *Memberlist) probeLoop() {
+	ticker := time.NewTicker(m.cfg.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+		m.reap()
+		m.mu.Lock()
+		active := m.joined && !m.left
+		m.mu.Unlock()
+		if active {
+			m.probe()
+		}
+	}
+}
+
+// probe 探测下一个成员，直接探测与间接探测都失败时将其标记为 suspect
+func (m *Memberlist) probe() {
+	target, ok := m.nextTarget()
+	if !ok {
+		// 还没有其他成员，继续联系种子节点
+		m.join(context.Background())
+		return
+	}
+	seq, ch := m.waitAck()
+	defer m.dropAck(seq)
+
+	m.send(target.Addr, message{Type: msgPing, Seq: seq})
+	select {
+	case <-ch:
+		return
+	case <-m.done:
+		return
+	case <-time.After(m.cfg.ProbeTimeout):
+	}
+
+	for _, relay := range m.randomMembers(m.cfg.IndirectChecks, target.Name) {
+		m.send(relay.Addr, message{Type: msgPingReq, Seq: seq, Target: target.Addr})
+	}
+	select {
+	case <-ch:
+		return
+	case <-m.done:
+		return
+	case <-time.After(m.cfg.ProbeInterval - m.cfg.ProbeTimeout):
+	}
+
+	m.mu.Lock()
+	if cur, ok := m.members[target.Name]; ok && cur.State == StateAlive && cur.Incarnation == target.Incarnation {
+		suspect := cur.Member
+		suspect.State = StateSuspect
+		m.apply(suspect)
+	}
+	m.mu.Unlock()
+	m.notify()
+}
+
+// nextTarget 按随机顺序轮流返回 alive 与 suspect 的成员
+func (m *Memberlist) nextTarget() (Member, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for {
+		if len(m.probeOrder) == 0 {
+			for name, mb := range m.members {
+				if mb.State != StateDead {
+					m.probeOrder = append(m.probeOrder, name)
+				}
+			}
+			if len(m.probeOrder) == 0 {
+				return Member{}, false
+			}
+			rand.Shuffle(len(m.probeOrder), func(i, j int) {
+				m.probeOrder[i], m.probeOrder[j] = m.probeOrder[j], m.probeOrder[i]
+			})
+		}
+		name := m.probeOrder[0]
+		m.probeOrder = m.probeOrder[1:]
+		if mb, ok := m.members[name]; ok && mb.State != StateDead {
+			return mb.Member, true
+		}
+	}
+}
+
+// randomMembers 随机返回至多 k 个 alive 的成员，不包括 exclude
+func (m *Memberlist) randomMembers(k int, exclude string) []Member {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var candidates []Member
+	for name, mb := range m.members {
+		if name != exclude && mb.State == StateAlive {
+			candidates = append(candidates, mb.Member)
+		}
+	}
+	rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	return candidates
+}
+
+// merge 应用收到的成员变化，成员列表变化时通知 Watch
+func (m *Memberlist) merge(updates []Member) {
+	if len(updates) == 0 {
+		return
+	}
+	changed := false
+	m.mu.Lock()
+	for _, u := range updates {
+		if m.apply(u) {
+			changed = true
+		}
+	}
+	m.mu.Unlock()
+	if changed {
+		m.notify()
+	}
+}
+
+// apply 应用一个成员变化并继续传播，返回成员的状态是否变化，调用前需持有 m.mu
+func (m *Memberlist) apply(u Member) bool {
+	if u.Name == "" {
+		return false
+	}
+	if u.Name == m.self.Name {
+		// 其他节点怀疑自己时增大 Incarnation 反驳
+		if m.joined && !m.left && u.State != StateAlive && u.Incarnation >= m.self.Incarnation {
+			m.self.Incarnation = u.Incarnation + 1
+			m.queue(m.self)
+		}
+		return false
+	}
+
+	cur, ok := m.members[u.Name]
+	if !ok {
+		if u.State == StateDead {
+			return false
+		}
+		cur = &member{Member: u}
+		m.members[u.Name] = cur
+	} else if supersedes(u, cur.Member) {
+		cur.Member = u
+	} else {
+		return false
+	}
+
+	if cur.suspectTimer != nil {
+		cur.suspectTimer.Stop()
+		cur.suspectTimer = nil
+	}
+	cur.deadAt = time.Time{}
+	if u.State == StateDead {
+		cur.deadAt = time.Now()
+	}
+	if u.State == StateSuspect {
+		cur.suspectTimer = time.AfterFunc(m.cfg.SuspicionTimeout, func() { m.suspicionExpired(u) })
+	}
+	if u.State != StateAlive {
+		m.logger.Info("[gossip] member "+u.State.String(), "name", u.Name, "incarnation", u.Incarnation)
+	}
+	m.queue(u)
+	return true
+}
+
+// suspicionExpired 在 suspect 没有被反驳时将节点标记为 dead
+func (m *Memberlist) suspicionExpired(u Member) {
+	m.mu.Lock()
+	changed := false
+	if cur, ok := m.members[u.Name]; ok && cur.State == StateSuspect && cur.Incarnation == u.Incarnation {
+		dead := cur.Member
+		dead.State = StateDead
+		changed = m.apply(dead)
+	}
+	m.mu.Unlock()
+	if changed {
+		m.notify()
+	}
+}
+
+// reap 删除 dead 超过 DeadReapTimeout 的成员，避免成员表与同步的数据随节点更替无限增长
+func (m *Memberlist) reap() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, mb := range m.members {
+		if mb.State == StateDead && time.Since(mb.deadAt) >= m.cfg.DeadReapTimeout {
+			delete(m.members, name)
+		}
+	}
+}
+
+// snapshot 返回包括自身在内的全部成员，用于 push-pull 同步
+func (m *Memberlist) snapshot() []Member {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	members := make([]Member, 0, len(m.members)+1)
+	if m.joined {
+		members = append(members, m.self)
+	}
+	for _, mb := range m.members {
+		members = append(members, mb.Member)
+	}
+	return members
+}
+
+// queue 将成员变化加入待传播的队列，替换同一节点较旧的变化，调用前需持有 m.mu
+func (m *Memberlist) queue(u Member) {
+	for i, b := range m.broadcasts {
+		if b.member.Name == u.Name {
+			m.broadcasts = append(m.broadcasts[:i], m.broadcasts[i+1:]...)
+			break
+		}
+	}
+	m.broadcasts = append(m.broadcasts, &broadcast{member: u})
+}
+
+// piggyback 取出发送次数最少的若干个成员变化，发送足够多次的变化不再传播，调用前需持有 m.mu
+func (m *Memberlist) piggyback() []Member {
+	limit := m.cfg.RetransmitMult * int(math.Ceil(math.Log10(float64(len(m.members)+2))))
+	sort.SliceStable(m.broadcasts, func(i, j int) bool { return m.broadcasts[i].transmits < m.broadcasts[j].transmits })
+	var updates []Member
+	kept := m.broadcasts[:0]
+	for _, b := range m.broadcasts {
+		if len(updates) < maxPiggyback {
+			updates = append(updates, b.member)
+			b.transmits++
+		}
+		if b.transmits < limit {
+			kept = append(kept, b)
+		}
+	}
+	m.broadcasts = kept
+	return updates
+}
+
+// send 捎带成员变化后将 msg 发送到 addr
+func (m *Memberlist) send(addr string, msg message) {
+	m.mu.Lock()
+	msg.Updates = append(msg.Updates, m.piggyback()...)
+	m.mu.Unlock()
+	m.sendRaw(addr, msg)
+}
+
+// sendRaw 将 msg 发送到 addr
+func (m *Memberlist) sendRaw(addr string, msg message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		m.logger.Error("[gossip] encode message failed", "err", err)
+		return
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		m.logger.Error("[gossip] resolve address failed", "addr", addr, "err", err)
+		return
+	}
+	m.conn.WriteTo(data, udpAddr)
+}
+
+// waitAck 分配一个序号，收到该序号的 ack 时返回的 channel 可读
+func (m *Memberlist) waitAck() (uint64, chan struct{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	ch := make(chan struct{}, 1)
+	m.acks[m.seq] = ch
+	return m.seq, ch
+}
+
+func (m *Memberlist) dropAck(seq uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.acks, seq)
+}
+
+// notify 通知 Watch 成员可能发生了变化
+func (m *Memberlist) notify() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ch := range m.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var _ registry.MetadataRegistry = (*Memberlist)(nil)
@@ -0,0 +1,241 @@
+package gossip
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gocache/registry"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestMemberlist 创建一个探测间隔很短的节点，测试结束时关闭
+func newTestMemberlist(t *testing.T, name string, seeds ...string) *Memberlist {
+	t.Helper()
+	m, err := Create(Config{
+		BindAddr:         "127.0.0.1:0",
+		Seeds:            seeds,
+		Weight:           len(name),
+		Zone:             "zone-" + name,
+		ProbeInterval:    50 * time.Millisecond,
+		ProbeTimeout:     20 * time.Millisecond,
+		SuspicionTimeout: 200 * time.Millisecond,
+		Logger:           nopLogger{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	if err := m.Register(context.Background(), "gocache", name); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+// waitMembers 等待 m 看到的成员变为 want
+func waitMembers(t *testing.T, m *Memberlist, want ...string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		got, _ := m.Resolve(context.Background(), "gocache")
+		if reflect.DeepEqual(got, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: expected members %v, got %v", m.self.Name, want, got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMemberlistJoin(t *testing.T) {
+	a := newTestMemberlist(t, "a:9999")
+	b := newTestMemberlist(t, "bb:9998", a.Addr())
+	c := newTestMemberlist(t, "ccc:9997", b.Addr())
+
+	for _, m := range []*Memberlist{a, b, c} {
+		waitMembers(t, m, "a:9999", "bb:9998", "ccc:9997")
+	}
+	for _, mb := range a.Members() {
+		if mb.Weight != len(mb.Name) || mb.Zone != "zone-"+mb.Name || mb.State != StateAlive {
+			t.Fatalf("unexpected member %+v", mb)
+		}
+	}
+}
+
+func TestMemberlistWatch(t *testing.T) {
+	a := newTestMemberlist(t, "a:9999")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan []string, 16)
+	go a.Watch(ctx, "gocache", func(addrs []string) { ch <- addrs })
+
+	expect := func(want ...string) {
+		t.Helper()
+		select {
+		case got := <-ch:
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("expected %v, got %v", want, got)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting for %v", want)
+		}
+	}
+	expect("a:9999")
+	b := newTestMemberlist(t, "b:9998", a.Addr())
+	expect("a:9999", "b:9998")
+	b.Deregister(ctx, "gocache", "b:9998")
+	expect("a:9999")
+}
+
+func TestMemberlistFailureDetection(t *testing.T) {
+	a := newTestMemberlist(t, "a:9999")
+	b := newTestMemberlist(t, "b:9998", a.Addr())
+	c := newTestMemberlist(t, "c:9997", a.Addr())
+	waitMembers(t, a, "a:9999", "b:9998", "c:9997")
+	waitMembers(t, b, "a:9999", "b:9998", "c:9997")
+
+	// c 不通知其他节点直接退出，先被怀疑，怀疑超时后被移除
+	c.Close()
+	waitMembers(t, a, "a:9999", "b:9998")
+	waitMembers(t, b, "a:9999", "b:9998")
+	a.mu.Lock()
+	state := a.members["c:9997"].State
+	a.mu.Unlock()
+	if state != StateDead {
+		t.Fatalf("expected c to be dead, got %s", state)
+	}
+}
+
+func TestMemberlistRejoin(t *testing.T) {
+	a := newTestMemberlist(t, "a:9999")
+	b := newTestMemberlist(t, "b:9998", a.Addr())
+	waitMembers(t, a, "a:9999", "b:9998")
+
+	b.Deregister(context.Background(), "gocache", "b:9998")
+	waitMembers(t, a, "a:9999")
+	b.Register(context.Background(), "gocache", "b:9998")
+	waitMembers(t, a, "a:9999", "b:9998")
+
+	// 重启后 Incarnation 从 0 开始的节点通过反驳 dead 重新加入
+	b.Close()
+	waitMembers(t, a, "a:9999")
+	newTestMemberlist(t, "b:9998", a.Addr())
+	waitMembers(t, a, "a:9999", "b:9998")
+}
+
+func TestSupersedes(t *testing.T) {
+	member := func(s State, inc uint64) Member { return Member{State: s, Incarnation: inc} }
+	tests := []struct {
+		n, o Member
+		want bool
+	}{
+		{member(StateAlive, 1), member(StateAlive, 0), true},
+		{member(StateAlive, 1), member(StateAlive, 1), false},
+		{member(StateAlive, 1), member(StateSuspect, 1), false},
+		{member(StateAlive, 2), member(StateSuspect, 1), true},
+		{member(StateAlive, 2), member(StateDead, 1), true},
+		{member(StateSuspect, 1), member(StateAlive, 1), true},
+		{member(StateSuspect, 0), member(StateAlive, 1), false},
+		{member(StateSuspect, 1), member(StateSuspect, 1), false},
+		{member(StateSuspect, 2), member(StateSuspect, 1), true},
+		{member(StateSuspect, 2), member(StateDead, 1), false},
+		{member(StateDead, 1), member(StateSuspect, 1), true},
+		{member(StateDead, 1), member(StateAlive, 1), true},
+		{member(StateDead, 0), member(StateAlive, 1), false},
+		{member(StateDead, 2), member(StateDead, 1), false},
+	}
+	for _, tt := range tests {
+		if got := supersedes(tt.n, tt.o); got != tt.want {
+			t.Errorf("supersedes(%+v, %+v) = %v, want %v", tt.n, tt.o, got, tt.want)
+		}
+	}
+}
+
+func TestMemberlistRefute(t *testing.T) {
+	a := newTestMemberlist(t, "a:9999")
+	a.mu.Lock()
+	a.apply(Member{Node: Node{Name: "a:9999"}, State: StateSuspect, Incarnation: 3})
+	self := a.self
+	a.mu.Unlock()
+	if self.State != StateAlive || self.Incarnation != 4 {
+		t.Fatalf("expected alive with incarnation 4, got %+v", self)
+	}
+}
+
+func TestMemberlistMetadata(t *testing.T) {
+	a := newTestMemberlist(t, "a:9999")
+	b := newTestMemberlist(t, "b:9998", a.Addr())
+	waitMembers(t, a, "a:9999", "b:9998")
+
+	md := registry.Metadata{Weight: 8, Version: "v2", Features: []string{"getmulti"}}
+	if err := b.RegisterInstance(context.Background(), "gocache", registry.Instance{Addr: "b:9998", Metadata: md}); err != nil {
+		t.Fatal(err)
+	}
+	md.Zone = "zone-b:9998" // 没有指定的字段沿用 Config
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		instances, _ := a.ResolveInstances(context.Background(), "gocache")
+		if len(instances) == 2 && reflect.DeepEqual(instances[1].Metadata, md) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("metadata of b should reach a, got %+v", instances)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMemberlistJoinLargeCluster(t *testing.T) {
+	a := newTestMemberlist(t, "a:9999")
+	// 全部成员编码后远大于一个 UDP 包，需要通过 TCP 同步给新节点
+	const n = 2000
+	a.mu.Lock()
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("node-%04d:9000", i)
+		a.members[name] = &member{Member: Member{Node: Node{Name: name, Addr: "127.0.0.1:1", Zone: "zone-" + name}}}
+	}
+	a.mu.Unlock()
+	if data, _ := json.Marshal(a.snapshot()); len(data) <= maxPacketSize {
+		t.Fatalf("snapshot of %d bytes should not fit in a UDP packet", len(data))
+	}
+
+	b := newTestMemberlist(t, "b:9998", a.Addr())
+	b.mu.Lock()
+	got := len(b.members)
+	b.mu.Unlock()
+	if got != n+1 {
+		t.Fatalf("expected b to learn %d members on join, got %d", n+1, got)
+	}
+}
+
+func TestMemberlistReapDead(t *testing.T) {
+	a := newTestMemberlist(t, "a:9999")
+	b := newTestMemberlist(t, "b:9998", a.Addr())
+	waitMembers(t, a, "a:9999", "b:9998")
+	a.mu.Lock()
+	a.cfg.DeadReapTimeout = 100 * time.Millisecond
+	a.mu.Unlock()
+
+	b.Close()
+	waitMembers(t, a, "a:9999")
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		a.mu.Lock()
+		_, ok := a.members["b:9998"]
+		a.mu.Unlock()
+		if !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("dead member b should be reaped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
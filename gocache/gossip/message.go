@@ -0,0 +1,27 @@
+package gossip
+
+// message模块定义节点之间通过 UDP 发送的消息，全部成员的同步通过 TCP 进行，见 pushPull
+
+type msgType uint8
+
+const (
+	msgPing    msgType = iota // 直接探测，对方回复 msgAck
+	msgAck                    // 探测的回复
+	msgPingReq                // 请求对方代为探测 Target，收到 Target 的 ack 后回复 msgAck
+	msgGossip                 // 只携带成员变化，例如离开集群
+)
+
+// message 是 JSON 编码后放在一个 UDP 包中发送的消息
+// 每个消息都会捎带最近的成员变化，成员变化因此在集群中传播
+type message struct {
+	Type    msgType  `json:"t"`
+	Seq     uint64   `json:"q,omitempty"`
+	Target  string   `json:"g,omitempty"` // msgPingReq 要探测的节点的 UDP 地址
+	Updates []Member `json:"u,omitempty"`
+}
+
+// broadcast 是等待捎带发送的成员变化
+type broadcast struct {
+	member    Member
+	transmits int // 已经发送的次数
+}
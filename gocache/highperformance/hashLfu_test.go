@@ -327,4 +327,26 @@ func HashlfuPerformanceOne(h *HashLfuCache, c *sync.WaitGroup, k int) {
 
 	// 通知main已经结束循环(我搞定了!)
 	c.Done()
-}
\ No newline at end of file
+}
+
+func TestHashLFUStats(t *testing.T) {
+	l, err := NewHashLFU(4, 2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		l.Add(i, i, 0)
+	}
+	evictions := l.Stats().Evictions
+	if evictions != int64(10-l.Len()) {
+		t.Errorf("bad evictions: %v with %v items left", evictions, l.Len())
+	}
+	for _, k := range l.Keys() {
+		l.Remove(k)
+	}
+	l.Purge()
+	if s := l.Stats(); s.Evictions != evictions || s.Expirations != 0 {
+		t.Errorf("removals should not be counted: %+v", s)
+	}
+}
@@ -14,11 +14,13 @@ type HashLruCache struct {
 	list     []*HashLruCacheOne
 	sliceNum int
 	size     int
+	counter  counter
 }
 
 type HashLruCacheOne struct {
-	lru  simplelru.LRUCache
-	lock sync.RWMutex
+	lru      simplelru.LRUCache
+	lock     sync.RWMutex
+	removing bool // 正在执行 Remove 或 Purge，此时离开分片的条目不计入淘汰
 }
 
 // NewHashLRU creates an LRU of the given size.
@@ -46,10 +48,9 @@ func NewHashLruWithEvict(size, sliceNum int, onEvicted func(key interface{}, val
 	h.sliceNum = sliceNum
 	h.list = make([]*HashLruCacheOne, sliceNum)
 	for i := 0; i < sliceNum; i++ {
-		l, _ := simplelru.NewLRU(lruLen, onEvicted)
-		h.list[i] = &HashLruCacheOne{
-			lru: l,
-		}
+		one := &HashLruCacheOne{}
+		one.lru, _ = simplelru.NewLRU(lruLen, h.counter.wrap(&one.removing, onEvicted))
+		h.list[i] = one
 	}
 
 	return &h, nil
@@ -60,7 +61,9 @@ func NewHashLruWithEvict(size, sliceNum int, onEvicted func(key interface{}, val
 func (h *HashLruCache) Purge() {
 	for i := 0; i < h.sliceNum; i++ {
 		h.list[i].lock.Lock()
+		h.list[i].removing = true
 		h.list[i].lru.Purge()
+		h.list[i].removing = false
 		h.list[i].lock.Unlock()
 	}
 }
@@ -167,7 +170,27 @@ func (h *HashLruCache) Remove(key interface{}) (present bool) {
 	sliceKey := h.modulus(&key)
 
 	h.list[sliceKey].lock.Lock()
+	h.list[sliceKey].removing = true
 	present = h.list[sliceKey].lru.Remove(key)
+	h.list[sliceKey].removing = false
+	h.list[sliceKey].lock.Unlock()
+	return
+}
+
+// RemoveOldest removes the oldest item from the largest slice.
+// RemoveOldest 从条数最多的分片中移除最老的项
+func (h *HashLruCache) RemoveOldest() (key interface{}, value interface{}, expirationTime int64, ok bool) {
+	sliceKey, maxLen := 0, -1
+	for i := 0; i < h.sliceNum; i++ {
+		h.list[i].lock.RLock()
+		if l := h.list[i].lru.Len(); l > maxLen {
+			sliceKey, maxLen = i, l
+		}
+		h.list[i].lock.RUnlock()
+	}
+
+	h.list[sliceKey].lock.Lock()
+	key, value, expirationTime, ok = h.list[sliceKey].lru.RemoveOldest()
 	h.list[sliceKey].lock.Unlock()
 	return
 }
@@ -239,6 +262,12 @@ func (h *HashLruCache) Len() int {
 	return length
 }
 
+// Stats returns how many entries have been evicted or expired.
+// Stats 返回所有分片中因容量淘汰与因过期删除的条目数
+func (h *HashLruCache) Stats() Stats {
+	return h.counter.stats()
+}
+
 func (h *HashLruCache) modulus (key *interface{}) int {
 	str := InterfaceToString(*key)
 	return int(md5.Sum([]byte(str))[0]) % h.sliceNum
@@ -278,6 +278,28 @@ func TestHashLRUResize(t *testing.T) {
 	}
 }
 
+func TestHashLRURemoveOldest(t *testing.T) {
+	l, err := NewHashLRU(64, 4)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		l.Add(i, i, 0)
+	}
+	for i := 10; i > 0; i-- {
+		if _, _, _, ok := l.RemoveOldest(); !ok {
+			t.Fatalf("RemoveOldest should succeed with %d items left", i)
+		}
+		if l.Len() != i-1 {
+			t.Fatalf("bad len: %v", l.Len())
+		}
+	}
+	if _, _, _, ok := l.RemoveOldest(); ok {
+		t.Fatalf("RemoveOldest on empty cache should fail")
+	}
+}
+
 // HashLRU 性能压测
 func TestHashLRU_Performance(t *testing.T) {
 	runtime.GOMAXPROCS(runtime.NumCPU())
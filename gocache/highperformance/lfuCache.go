@@ -8,8 +8,10 @@ import (
 // LfuCache is a thread-safe fixed size LRU cache.
 // LfuCache 实现一个给定大小的LFU缓存
 type LfuCache struct {
-	lfu  simplelfu.LFUCache
-	lock sync.RWMutex
+	lfu      simplelfu.LFUCache
+	lock     sync.RWMutex
+	counter  counter
+	removing bool // 正在执行 Remove 或 Purge，此时离开缓存的条目不计入淘汰
 }
 
 // NewLFU creates an LRU of the given size.
@@ -22,10 +24,8 @@ func NewLFU(size int) (*LfuCache, error) {
 // callback.
 // NewLruWithEvict 用于在缓存条目被淘汰时的回调函数
 func NewLfuWithEvict(size int, onEvicted func(key interface{}, value interface{}, expirationTime int64)) (*LfuCache, error) {
-	lfu, _ := simplelfu.NewLFU(size, simplelfu.EvictCallback(onEvicted))
-	c := &LfuCache{
-		lfu: lfu,
-	}
+	c := &LfuCache{}
+	c.lfu, _ = simplelfu.NewLFU(size, c.counter.wrap(&c.removing, onEvicted))
 	return c, nil
 }
 
@@ -33,7 +33,9 @@ func NewLfuWithEvict(size int, onEvicted func(key interface{}, value interface{}
 // Purge 用于完全清除缓存
 func (c *LfuCache) Purge() {
 	c.lock.Lock()
+	c.removing = true
 	c.lfu.Purge()
+	c.removing = false
 	c.lock.Unlock()
 }
 
@@ -119,7 +121,9 @@ func (c *LfuCache) PeekOrAdd(key, value interface{}, expirationTime int64) (prev
 // Remove 从缓存中移除提供的键
 func (c *LfuCache) Remove(key interface{}) (present bool) {
 	c.lock.Lock()
+	c.removing = true
 	present = c.lfu.Remove(key)
+	c.removing = false
 	c.lock.Unlock()
 	return
 }
@@ -175,4 +179,10 @@ func (c *LfuCache) Len() int {
 	length := c.lfu.Len()
 	c.lock.RUnlock()
 	return length
-}
\ No newline at end of file
+}
+
+// Stats returns how many entries have been evicted or expired.
+// Stats 返回因容量淘汰与因过期删除的条目数
+func (c *LfuCache) Stats() Stats {
+	return c.counter.stats()
+}
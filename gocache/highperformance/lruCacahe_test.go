@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 )
 
 func BenchmarkLRU_Rand(b *testing.B) {
@@ -347,4 +348,24 @@ func LruPerformanceOne(h *LruCache, c *sync.WaitGroup, k int) {
 
 	// 通知main已经结束循环(我搞定了!)
 	c.Done()
-}
\ No newline at end of file
+}
+
+// test that Stats counts evictions and expirations but not removals
+func TestLRUStats(t *testing.T) {
+	l, err := NewLRU(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Add(1, 1, 0)
+	l.Add(2, 2, 0)
+	l.Add(3, 3, 0)
+	l.Add(4, 4, time.Now().UnixNano()/1e6-1)
+	if _, _, ok := l.Get(4); ok {
+		t.Errorf("4 should be expired")
+	}
+	l.Remove(3)
+	if s := l.Stats(); s.Evictions != 2 || s.Expirations != 1 {
+		t.Errorf("bad stats: %+v", s)
+	}
+}
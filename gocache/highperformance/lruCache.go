@@ -9,8 +9,10 @@ import (
 // LruCache is a thread-safe fixed size LRU cache.
 // LruCache 实现一个给定大小的LRU缓存
 type LruCache struct {
-	lru  simplelru.LRUCache
-	lock sync.RWMutex
+	lru      simplelru.LRUCache
+	lock     sync.RWMutex
+	counter  counter
+	removing bool // 正在执行 Remove 或 Purge，此时离开缓存的条目不计入淘汰
 }
 
 // NewLRU creates an LRU of the given size.
@@ -23,13 +25,12 @@ func NewLRU(size int) (*LruCache, error) {
 // callback.
 // NewLruWithEvict 用于在缓存条目被淘汰时的回调函数
 func NewLruWithEvict(size int, onEvicted func(key interface{}, value interface{}, expirationTime int64)) (*LruCache, error) {
-	lru, err := simplelru.NewLRU(size, simplelru.EvictCallback(onEvicted))
+	c := &LruCache{}
+	lru, err := simplelru.NewLRU(size, c.counter.wrap(&c.removing, onEvicted))
 	if err != nil {
 		return nil, err
 	}
-	c := &LruCache{
-		lru: lru,
-	}
+	c.lru = lru
 	return c, nil
 }
 
@@ -37,7 +38,9 @@ func NewLruWithEvict(size int, onEvicted func(key interface{}, value interface{}
 // Purge 清除所有缓存项
 func (c *LruCache) Purge() {
 	c.lock.Lock()
+	c.removing = true
 	c.lru.Purge()
+	c.removing = false
 	c.lock.Unlock()
 }
 
@@ -127,7 +130,9 @@ func (c *LruCache) PeekOrAdd(key, value interface{}, expirationTime int64) (prev
 // Remove 从缓存中移除提供的键。
 func (c *LruCache) Remove(key interface{}) (present bool) {
 	c.lock.Lock()
+	c.removing = true
 	present = c.lru.Remove(key)
+	c.removing = false
 	c.lock.Unlock()
 	return
 }
@@ -176,3 +181,9 @@ func (c *LruCache) Len() int {
 	c.lock.RUnlock()
 	return length
 }
+
+// Stats returns how many entries have been evicted or expired.
+// Stats 返回因容量淘汰与因过期删除的条目数
+func (c *LruCache) Stats() Stats {
+	return c.counter.stats()
+}
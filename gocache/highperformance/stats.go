@@ -0,0 +1,40 @@
+package highperformance
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats 统计条目离开缓存的原因
+type Stats struct {
+	Evictions   int64 // 因容量不足被淘汰的条目数
+	Expirations int64 // 因过期被删除的条目数
+}
+
+// counter 包装用户的淘汰回调，在回调中按原因以原子操作累加计数
+// Remove 与 Purge 主动删除的条目不计入统计，调用方需在持有写锁时设置 removing
+type counter struct {
+	evictions   int64
+	expirations int64
+}
+
+func (c *counter) wrap(removing *bool, onEvicted func(key interface{}, value interface{}, expirationTime int64)) func(key interface{}, value interface{}, expirationTime int64) {
+	return func(key interface{}, value interface{}, expirationTime int64) {
+		switch {
+		case expirationTime != 0 && expirationTime <= time.Now().UnixNano()/1e6:
+			atomic.AddInt64(&c.expirations, 1)
+		case !*removing:
+			atomic.AddInt64(&c.evictions, 1)
+		}
+		if onEvicted != nil {
+			onEvicted(key, value, expirationTime)
+		}
+	}
+}
+
+func (c *counter) stats() Stats {
+	return Stats{
+		Evictions:   atomic.LoadInt64(&c.evictions),
+		Expirations: atomic.LoadInt64(&c.expirations),
+	}
+}
@@ -0,0 +1,67 @@
+package gocache
+
+import (
+	"log/slog"
+
+	"go.uber.org/zap"
+)
+
+// logger 模块定义 gocache 输出日志使用的接口
+// 每个请求都会经过的路径只输出 Debug 级别的日志，默认不会打印
+
+// Logger 是分级的结构化日志接口，args 为交替出现的 key 与 value
+// *slog.Logger 直接实现了该接口
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// NewSlogLogger 使用 l 输出日志，l 为 nil 时使用 slog.Default()
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		return slog.Default()
+	}
+	return l
+}
+
+// zapLogger 将日志转发给 zap
+type zapLogger struct {
+	s *zap.SugaredLogger
+}
+
+// NewZapLogger 使用 l 输出日志
+func NewZapLogger(l *zap.Logger) Logger {
+	return zapLogger{s: l.Sugar()}
+}
+
+func (l zapLogger) Debug(msg string, args ...interface{}) { l.s.Debugw(msg, args...) }
+func (l zapLogger) Info(msg string, args ...interface{})  { l.s.Infow(msg, args...) }
+func (l zapLogger) Warn(msg string, args ...interface{})  { l.s.Warnw(msg, args...) }
+func (l zapLogger) Error(msg string, args ...interface{}) { l.s.Errorw(msg, args...) }
+
+// nopLogger 丢弃所有日志
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Info(msg string, args ...interface{})  {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
+func (nopLogger) Error(msg string, args ...interface{}) {}
+
+// NopLogger 丢弃所有日志
+var NopLogger Logger = nopLogger{}
+
+// loggerOrDefault 在 l 为 nil 时返回 slog.Default()
+// 每次调用时才读取 slog.Default()，使 slog.SetDefault 对未设置 Logger 的 server 和 Group 同样生效
+func loggerOrDefault(l Logger) Logger {
+	if l == nil {
+		return slog.Default()
+	}
+	return l
+}
+
+var (
+	_ Logger = (*slog.Logger)(nil)
+	_ Logger = zapLogger{}
+)
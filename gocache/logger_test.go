@@ -0,0 +1,68 @@
+package gocache
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// 测试命中日志只在 Debug 级别输出
+func TestGroupLogger(t *testing.T) {
+	var debug, info bytes.Buffer
+	newLogger := func(buf *bytes.Buffer, level slog.Level) Logger {
+		return NewSlogLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: level})))
+	}
+	g := NewGroup("loggerDebug", 1<<10, time.Minute, GetterFunc(mockGetter), WithLogger(newLogger(&debug, slog.LevelDebug)))
+	g.Get("Tom")
+	g.Get("Tom")
+	if out := debug.String(); !strings.Contains(out, "level=DEBUG") || !strings.Contains(out, "group=loggerDebug") {
+		t.Fatalf("hit should be logged at debug level, got %q", out)
+	}
+
+	g = NewGroup("loggerInfo", 1<<10, time.Minute, GetterFunc(mockGetter), WithLogger(newLogger(&info, slog.LevelInfo)))
+	g.Get("Tom")
+	g.Get("Tom")
+	if info.Len() != 0 {
+		t.Fatalf("nothing should be logged at info level, got %q", info.String())
+	}
+}
+
+// 测试 zap 适配器保留日志级别与字段
+func TestZapLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := NewZapLogger(zap.New(core))
+	l.Debug("hit", "group", "scores")
+	l.Warn("failed", "err", "timeout")
+
+	entries := logs.All()
+	if len(entries) != 2 || entries[0].Level != zapcore.DebugLevel || entries[1].Level != zapcore.WarnLevel {
+		t.Fatalf("unexpected entries %v", entries)
+	}
+	if entries[0].ContextMap()["group"] != "scores" {
+		t.Fatalf("fields should be kept, got %v", entries[0].ContextMap())
+	}
+}
+
+// 测试 server 的 Logger 传递给它创建的 client
+func TestServerLogger(t *testing.T) {
+	svr, err := NewServer("localhost:9996")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	svr.SetLogger(NopLogger)
+	svr.SetPeers("localhost:9996", "localhost:9995")
+	for addr, c := range svr.clients {
+		if c.logger() != NopLogger {
+			t.Fatalf("client %s should use the server's logger", addr)
+		}
+	}
+	if loggerOrDefault(nil) != slog.Default() {
+		t.Fatalf("nil logger should fall back to slog.Default()")
+	}
+}
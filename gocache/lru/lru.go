@@ -2,13 +2,17 @@ package lru
 
 import (
 	"container/list"
-	"time"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// Cache is a LRU cache. It is not safe for concurrnet access
+// Cache is a LRU cache. It is safe for concurrent access
+// 后台清理过期条目的协程与调用方通过 mu 互斥
 type Cache struct {
-	mu	   sync.Mutex 
+	mu	   sync.Mutex
+	evictions   int64 // 因容量不足被淘汰的条目数，原子操作维护
+	expirations int64 // 因过期被删除的条目数，原子操作维护
 	capacity  int64                         //允许使用的最大内存
 	length    int64                         // 当前已使用的内存
 	doublyLinkedList   *list.List                    //指向list.List的指针
@@ -57,6 +61,8 @@ func New(maxBytes int64, onEvicted func(string, Lengthable)) *Cache {
 //get look ups a key's value
 //如果键对应的链表节点存在，则将对应节点移动到头部，并返回查找到的值。
 func (c *Cache) Get(key string) (value Lengthable,ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if ele,ok := c.hashmap[key];ok { //找到后ele是指向list.Element的指针
 	
 		//结构体Element中有名为Value的字段，类型是interface{},意味着其可以存储任意类型的值 ele.Value 存储了与该元素相关联的数据。
@@ -65,6 +71,7 @@ func (c *Cache) Get(key string) (value Lengthable,ok bool) {
 		// If the entry has expired, remove it from the cache
 		if !kv.expire.IsZero() && time.Now().After(kv.expire) {
 			c.removeElement(ele)
+			atomic.AddInt64(&c.expirations, 1)
 			return nil,false
 		}
 		c.doublyLinkedList.MoveToFront(ele)     //约定front是头部
@@ -74,6 +81,8 @@ func (c *Cache) Get(key string) (value Lengthable,ok bool) {
 }
 
 func (c *Cache) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if ele,ok := c.hashmap[key];ok {
 		c.removeElement(ele)
 	}
@@ -81,10 +90,17 @@ func (c *Cache) Remove(key string) {
 
 // RemoveOldest removes the oldest item
 func (c *Cache) RemoveOldest() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeOldest()
+}
+
+func (c *Cache) removeOldest() {
 	//取到末尾节点，从链表中删除
 	ele := c.doublyLinkedList.Back()
 	if ele != nil {
 		c.removeElement(ele)
+		atomic.AddInt64(&c.evictions, 1)
 	}
 }
 
@@ -101,6 +117,8 @@ func (c *Cache) removeElement(ele *list.Element) {
 
 // Add adds a value to the cache.
 func (c *Cache) Add(key string,value Lengthable,expire time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	//如果键存在，则更新对应节点的值，并将该节点移到头部。
 	expires := time.Now().Add(expire)
 	if expire == 0 {
@@ -121,20 +139,45 @@ func (c *Cache) Add(key string,value Lengthable,expire time.Duration) {
 		c.length += int64(len(key)) + int64(value.Len())
 	}//更新 c.nbytes，如果超过了设定的最大值 c.maxBytes，则移除最少访问的节点。
 	for c.capacity != 0 && c.capacity < c.length {
-		c.RemoveOldest()
+		c.removeOldest()
 	}
 }
 
 // Len the number of cache entries
 func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.hashmap == nil {
 		return 0
 	}
 	return c.doublyLinkedList.Len()
 }
 
+// Bytes the number of bytes used by keys and values
+func (c *Cache) Bytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.length
+}
+
+// Stats 统计条目离开缓存的原因，Remove 与 Clear 主动删除的条目不计入
+type Stats struct {
+	Evictions   int64 // 因容量不足被淘汰的条目数
+	Expirations int64 // 因过期被删除的条目数
+}
+
+// Stats returns how many entries have been evicted or expired
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Evictions:   atomic.LoadInt64(&c.evictions),
+		Expirations: atomic.LoadInt64(&c.expirations),
+	}
+}
+
 // Clear purges all stored items from the cache.
 func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.OnEvicted != nil {
 		for _, e := range c.hashmap {
 			kv := e.Value.(*entry)
@@ -151,20 +194,32 @@ func (c *Cache) startCleanupTimer() {
 	for {
 		select {
 		case <-ticker.C:
-			for e := c.doublyLinkedList.Back(); e != nil; e = e.Prev() {
-				kv := e.Value.(*entry)
-				if !kv.expire.IsZero() && time.Now().After(kv.expire) {
-					c.removeElement(e)
-				} else {
-					break
-				}
-			}
+			c.removeExpired()
 		case <-c.stopCh:
 			return
 		}
 	}
 }
 
+// removeExpired 从链表尾部开始删除已过期的条目，遇到未过期的条目即停止
+func (c *Cache) removeExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.doublyLinkedList == nil {
+		return
+	}
+	for e := c.doublyLinkedList.Back(); e != nil; {
+		kv := e.Value.(*entry)
+		if kv.expire.IsZero() || !time.Now().After(kv.expire) {
+			break
+		}
+		prev := e.Prev()
+		c.removeElement(e)
+		atomic.AddInt64(&c.expirations, 1)
+		e = prev
+	}
+}
+
 func (c *Cache) Stop() {
 	close(c.stopCh)
 }
\ No newline at end of file
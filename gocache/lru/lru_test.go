@@ -90,3 +90,23 @@ func TestCache_OnEvicted(t *testing.T) {
 		t.Fatalf("Expected eviction did not happen.")
 	}
 }
+
+func TestCache_Stats(t *testing.T) {
+	lru := New(int64(10), nil)
+	lru.Add("k1", String("v1"), 0)
+	lru.Add("k2", String("v2"), 0)
+	lru.Add("k3", String("v3"), 0) // 超出 10 字节，淘汰 k1
+	lru.Add("k4", String("v4"), time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if _, ok := lru.Get("k4"); ok {
+		t.Fatalf("k4 should be expired")
+	}
+	lru.Remove("k3")
+
+	if s := lru.Stats(); s.Evictions != 2 || s.Expirations != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	if lru.Len() != 0 || lru.Bytes() != 0 {
+		t.Fatalf("cache should be empty, got %d entries and %d bytes", lru.Len(), lru.Bytes())
+	}
+}
@@ -0,0 +1,183 @@
+// Package metrics 以 Prometheus 指标的形式导出 GoCache 的运行数据
+//
+// 用法:
+//
+//	metrics.Register(prometheus.DefaultRegisterer, svr)
+//	svr.EnableMetrics(":2112", metrics.Handler())
+package metrics
+
+import (
+	"errors"
+	"gocache"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/status"
+)
+
+const namespace = "gocache"
+
+// Ring 是一致性哈希环，gocache 的 server 实现了它
+type Ring interface {
+	Members() []string
+}
+
+// Exporter 同时实现 prometheus.Collector 与 gocache.MetricsRecorder
+// 计数与容量类指标在采集时从 Group.Stats() 读取，耗时类指标由 gocache 在请求完成时上报
+type Exporter struct {
+	ring Ring
+
+	loadLatency  *prometheus.HistogramVec
+	peerLatency  *prometheus.HistogramVec
+	peerRequests *prometheus.CounterVec
+	ringMembers  *prometheus.Desc
+}
+
+// groupCounter 描述一个从 gocache.Stats 读取的 Group 级计数器
+type groupCounter struct {
+	desc  *prometheus.Desc
+	value func(gocache.Stats) int64
+}
+
+// cacheMetric 描述一个从 gocache.CacheStats 读取的缓存级指标
+type cacheMetric struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	value     func(gocache.CacheStats) int64
+}
+
+func newGroupCounter(name, help string, value func(gocache.Stats) int64) groupCounter {
+	return groupCounter{
+		desc:  prometheus.NewDesc(prometheus.BuildFQName(namespace, "group", name), help, []string{"group"}, nil),
+		value: value,
+	}
+}
+
+func newCacheMetric(name, help string, valueType prometheus.ValueType, value func(gocache.CacheStats) int64) cacheMetric {
+	return cacheMetric{
+		desc:      prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", name), help, []string{"group", "cache", "backend"}, nil),
+		valueType: valueType,
+		value:     value,
+	}
+}
+
+var groupCounters = []groupCounter{
+	newGroupCounter("gets_total", "Number of keys requested from the group.", func(s gocache.Stats) int64 { return s.Gets }),
+	newGroupCounter("hits_total", "Number of requests served from mainCache or hotCache.", func(s gocache.Stats) int64 { return s.Hits }),
+	newGroupCounter("misses_total", "Number of requests that missed both caches.", func(s gocache.Stats) int64 { return s.Misses }),
+	newGroupCounter("peer_loads_total", "Number of values fetched from peers.", func(s gocache.Stats) int64 { return s.PeerLoads }),
+	newGroupCounter("peer_errors_total", "Number of failed fetches from peers.", func(s gocache.Stats) int64 { return s.PeerErrors }),
+	newGroupCounter("local_loads_total", "Number of values loaded by the Getter.", func(s gocache.Stats) int64 { return s.LocalLoads }),
+	newGroupCounter("loader_errors_total", "Number of Getter errors, not counting ErrNotFound.", func(s gocache.Stats) int64 { return s.LoaderErrors }),
+	newGroupCounter("dedups_total", "Number of loads merged by singleflight.", func(s gocache.Stats) int64 { return s.Dedups }),
+}
+
+var cacheMetrics = []cacheMetric{
+	newCacheMetric("bytes", "Bytes used by keys and values.", prometheus.GaugeValue, func(s gocache.CacheStats) int64 { return s.Bytes }),
+	newCacheMetric("items", "Number of entries.", prometheus.GaugeValue, func(s gocache.CacheStats) int64 { return s.Items }),
+	newCacheMetric("gets_total", "Number of lookups.", prometheus.CounterValue, func(s gocache.CacheStats) int64 { return s.Gets }),
+	newCacheMetric("hits_total", "Number of lookups that hit.", prometheus.CounterValue, func(s gocache.CacheStats) int64 { return s.Hits }),
+	newCacheMetric("evictions_total", "Number of entries evicted for capacity.", prometheus.CounterValue, func(s gocache.CacheStats) int64 { return s.Evictions }),
+	newCacheMetric("expirations_total", "Number of entries removed after expiring.", prometheus.CounterValue, func(s gocache.CacheStats) int64 { return s.Expirations }),
+}
+
+// NewExporter 创建 Exporter，ring 为 nil 时不导出哈希环的节点数
+func NewExporter(ring Ring) *Exporter {
+	return &Exporter{
+		ring: ring,
+		loadLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "load_duration_seconds",
+			Help:      "Latency of loading values through the Getter.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"group", "result"}),
+		peerLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "peer_request_duration_seconds",
+			Help:      "Latency of gRPC requests sent to peers.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"peer", "method"}),
+		peerRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "peer_requests_total",
+			Help:      "Number of gRPC requests sent to peers by status code.",
+		}, []string{"peer", "method", "code"}),
+		ringMembers: prometheus.NewDesc(prometheus.BuildFQName(namespace, "ring", "members"),
+			"Number of nodes in the consistent hash ring.", nil, nil),
+	}
+}
+
+// Register 创建 Exporter 并注册到 reg，同时将它设置为 gocache 的 MetricsRecorder
+func Register(reg prometheus.Registerer, ring Ring) (*Exporter, error) {
+	e := NewExporter(ring)
+	if err := reg.Register(e); err != nil {
+		return nil, err
+	}
+	gocache.SetMetricsRecorder(e)
+	return e, nil
+}
+
+// Handler 返回暴露 prometheus.DefaultGatherer 中指标的 HTTP handler
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Describe implements prometheus.Collector
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range groupCounters {
+		ch <- c.desc
+	}
+	for _, m := range cacheMetrics {
+		ch <- m.desc
+	}
+	if e.ring != nil {
+		ch <- e.ringMembers
+	}
+	e.loadLatency.Describe(ch)
+	e.peerLatency.Describe(ch)
+	e.peerRequests.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	for _, g := range gocache.Groups() {
+		s := g.Stats()
+		for _, c := range groupCounters {
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, float64(c.value(s)), g.Name())
+		}
+		for _, m := range cacheMetrics {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valueType, float64(m.value(s.MainCache)), g.Name(), "main", g.CacheType())
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valueType, float64(m.value(s.HotCache)), g.Name(), "hot", gocache.TYPE_SIMPLE)
+		}
+	}
+	if e.ring != nil {
+		ch <- prometheus.MustNewConstMetric(e.ringMembers, prometheus.GaugeValue, float64(len(e.ring.Members())))
+	}
+	e.loadLatency.Collect(ch)
+	e.peerLatency.Collect(ch)
+	e.peerRequests.Collect(ch)
+}
+
+// ObserveLoad implements gocache.MetricsRecorder
+func (e *Exporter) ObserveLoad(group string, d time.Duration, err error) {
+	result := "ok"
+	if errors.Is(err, gocache.ErrNotFound) {
+		result = "not_found"
+	} else if err != nil {
+		result = "error"
+	}
+	e.loadLatency.WithLabelValues(group, result).Observe(d.Seconds())
+}
+
+// ObservePeerRequest implements gocache.MetricsRecorder
+func (e *Exporter) ObservePeerRequest(peer string, method string, d time.Duration, err error) {
+	e.peerLatency.WithLabelValues(peer, method).Observe(d.Seconds())
+	e.peerRequests.WithLabelValues(peer, method, status.Code(err).String()).Inc()
+}
+
+var (
+	_ prometheus.Collector    = (*Exporter)(nil)
+	_ gocache.MetricsRecorder = (*Exporter)(nil)
+)
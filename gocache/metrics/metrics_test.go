@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"errors"
+	"gocache"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeRing []string
+
+func (r fakeRing) Members() []string {
+	return r
+}
+
+// 测试 Exporter 导出 Group 的计数、缓存容量、加载耗时和哈希环节点数
+func TestExporter(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	e, err := Register(reg, fakeRing{"localhost:9998", "localhost:9997"})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	defer gocache.SetMetricsRecorder(nil)
+
+	g := gocache.NewGroup("metrics", 1<<10, time.Minute, gocache.GetterFunc(func(key string) ([]byte, error) {
+		if key == "bad" {
+			return nil, errors.New("db down")
+		}
+		return []byte("v_" + key), nil
+	}))
+	g.Get("a")
+	g.Get("a")
+	g.Get("bad")
+
+	expected := `
+# HELP gocache_group_gets_total Number of keys requested from the group.
+# TYPE gocache_group_gets_total counter
+gocache_group_gets_total{group="metrics"} 3
+# HELP gocache_group_hits_total Number of requests served from mainCache or hotCache.
+# TYPE gocache_group_hits_total counter
+gocache_group_hits_total{group="metrics"} 1
+# HELP gocache_group_loader_errors_total Number of Getter errors, not counting ErrNotFound.
+# TYPE gocache_group_loader_errors_total counter
+gocache_group_loader_errors_total{group="metrics"} 1
+# HELP gocache_cache_items Number of entries.
+# TYPE gocache_cache_items gauge
+gocache_cache_items{backend="lru_simple",cache="hot",group="metrics"} 0
+gocache_cache_items{backend="lru_simple",cache="main",group="metrics"} 1
+# HELP gocache_ring_members Number of nodes in the consistent hash ring.
+# TYPE gocache_ring_members gauge
+gocache_ring_members 2
+`
+	names := []string{"gocache_cache_items", "gocache_group_gets_total", "gocache_group_hits_total", "gocache_group_loader_errors_total", "gocache_ring_members"}
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), names...); err != nil {
+		t.Fatalf("unexpected metrics: %v", err)
+	}
+
+	if n := testutil.CollectAndCount(e.loadLatency); n != 2 {
+		t.Fatalf("expected ok and error load series, got %d", n)
+	}
+}
+
+// 测试按 gRPC 状态码统计对等节点请求
+func TestExporterPeerRequests(t *testing.T) {
+	e := NewExporter(nil)
+	e.ObservePeerRequest("peer", "Get", time.Millisecond, nil)
+	e.ObservePeerRequest("peer", "Get", time.Millisecond, status.Error(codes.Unavailable, "down"))
+	e.ObservePeerRequest("peer", "Get", time.Millisecond, status.Error(codes.Unavailable, "down"))
+
+	if v := testutil.ToFloat64(e.peerRequests.WithLabelValues("peer", "Get", "Unavailable")); v != 2 {
+		t.Fatalf("expected 2 Unavailable requests, got %v", v)
+	}
+	if v := testutil.ToFloat64(e.peerRequests.WithLabelValues("peer", "Get", "OK")); v != 1 {
+		t.Fatalf("expected 1 OK request, got %v", v)
+	}
+}
@@ -1,5 +1,10 @@
 package gocache
 
+import (
+	"context"
+	"time"
+)
+
 // import pb "gocache/gocachepb"
 
 /*
@@ -27,4 +32,43 @@ type Picker interface {
 // 所以每个Peer应实现这个接口
 type Fetcher interface {
 	Fetch(group string, key string) ([]byte, error)
-}
\ No newline at end of file
+}
+
+// ContextFetcher 定义了在调用方的 deadline 内从远端获取缓存的能力
+// Peer 实现了它时，Group 会优先使用 FetchContext
+// 返回值应带上远端的过期时间(见 NewByteView)，使本地副本与远端同时过期
+type ContextFetcher interface {
+	FetchContext(ctx context.Context, group string, key string) (ByteView, error)
+}
+
+// BatchFetcher 定义了一次请求获取远端多个缓存值的能力
+// keyErrs 记录单个 key 的失败原因，err 非空代表整个请求失败
+type BatchFetcher interface {
+	FetchMulti(ctx context.Context, group string, keys []string) (values map[string]ByteView, keyErrs map[string]error, err error)
+}
+
+// Updater 定义了修改远端缓存的能力
+// Group.Set/Remove/Invalidate 通过它把写操作发送到目标节点
+type Updater interface {
+	Set(group string, key string, value []byte, ttl time.Duration) error
+	Remove(group string, key string) error
+	Invalidate(group string, key string) error
+}
+
+// OwnerPicker 定义了选出 key 所属节点的能力
+// 有界负载等模式下 Pick 可能为了分摊负载选出其他节点，而写操作需要发送给 key 真正所属的节点
+type OwnerPicker interface {
+	PickOwner(key string) (peer Fetcher, ok bool)
+}
+
+// ReplicaPicker 定义了选出 key 所有副本节点的能力
+// peers 为副本中的远端节点，self 表示本节点也是副本之一；两者皆为空代表写操作不复制，Group 改用 OwnerPicker 或 Picker
+type ReplicaPicker interface {
+	PickReplicas(key string) (peers []Fetcher, self bool)
+}
+
+// PeerLister 列出除自身外的所有远端节点
+// Invalidate 需要通知集群中的每一个节点
+type PeerLister interface {
+	Peers() []Fetcher
+}
@@ -0,0 +1,63 @@
+package gocache
+
+import (
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// pool模块让访问同一个远端节点的 client 共用一个 gRPC 连接
+// gRPC 连接本身支持并发请求，SetPeers 重建 client 或多个 client 指向同一节点时无需再次建立连接
+
+// connPool 保存以 target 为 key、带引用计数的 gRPC 连接
+type connPool struct {
+	mu    sync.Mutex
+	conns map[string]*pooledConn
+}
+
+type pooledConn struct {
+	conn *grpc.ClientConn
+	refs int // 持有该连接的 client 数
+}
+
+func newConnPool() *connPool {
+	return &connPool{conns: make(map[string]*pooledConn)}
+}
+
+// acquire 返回 target 的连接，没有时用 dial 建立，每次成功的 acquire 都需要对应一次 release
+func (p *connPool) acquire(target string, dial func() (*grpc.ClientConn, error)) (*grpc.ClientConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pc, ok := p.conns[target]; ok {
+		pc.refs++
+		return pc.conn, nil
+	}
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	p.conns[target] = &pooledConn{conn: conn, refs: 1}
+	return conn, nil
+}
+
+// release 释放一次对 target 连接的引用，最后一个引用释放时关闭连接
+func (p *connPool) release(target string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pc, ok := p.conns[target]
+	if !ok {
+		return nil
+	}
+	if pc.refs--; pc.refs > 0 {
+		return nil
+	}
+	delete(p.conns, target)
+	return pc.conn.Close()
+}
+
+// len 返回池中的连接数
+func (p *connPool) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.conns)
+}
@@ -0,0 +1,56 @@
+package gocache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// recorder 模块定义 gocache 向监控系统上报数据的接口
+// 核心包只依赖这个接口，gocache/metrics 子包提供了基于 Prometheus 的实现
+
+// MetricsRecorder 接收加载与远端请求的耗时，实现需要是并发安全的
+type MetricsRecorder interface {
+	// ObserveLoad 记录一次调用 Getter 的耗时，err 为加载结果
+	ObserveLoad(group string, d time.Duration, err error)
+	// ObservePeerRequest 记录一次访问远端节点的 gRPC 请求，method 为 gRPC 方法名
+	ObservePeerRequest(peer string, method string, d time.Duration, err error)
+}
+
+type recorderHolder struct {
+	MetricsRecorder
+}
+
+var metricsRecorder atomic.Value // recorderHolder
+
+// SetMetricsRecorder 设置全局的 MetricsRecorder，传入 nil 则停止上报
+func SetMetricsRecorder(r MetricsRecorder) {
+	metricsRecorder.Store(recorderHolder{r})
+}
+
+// getRecorder 返回当前的 MetricsRecorder，未设置时返回 nil
+func getRecorder() MetricsRecorder {
+	h, _ := metricsRecorder.Load().(recorderHolder)
+	return h.MetricsRecorder
+}
+
+// observeLoad 在设置了 MetricsRecorder 时记录一次加载
+func observeLoad(group string, start time.Time, err error) {
+	if r := getRecorder(); r != nil {
+		r.ObserveLoad(group, time.Since(start), err)
+	}
+}
+
+// peerInterceptor 为访问 peer 的每个 gRPC 请求记录耗时与结果
+func peerInterceptor(peer string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if r := getRecorder(); r != nil {
+			r.ObservePeerRequest(peer, method, time.Since(start), err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,162 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// 重新解析 DNS 记录的默认间隔
+const defaultDNSInterval = 30 * time.Second
+
+// DNSRegistry 通过解析 DNS 记录发现节点，例如 Kubernetes 的 headless service
+// 使用 A 记录时节点地址为解析出的 IPv4 与 IPv6(AAAA)地址加上固定端口；
+// 使用 SRV 记录时端口取自记录，目标主机再经 A/AAAA 记录解析为 IP 地址，无法解析的目标被跳过。
+// 节点列表由 DNS 决定，Register 与 Deregister 不做任何事，Watch 与 Resolve 忽略 service 参数
+type DNSRegistry struct {
+	name     string // 解析的域名
+	port     int    // 使用 A 记录时节点的端口，为 0 代表使用 SRV 记录
+	resolver *net.Resolver
+	interval time.Duration
+	logger   Logger
+}
+
+// DNSOption 用于配置 DNSRegistry
+type DNSOption func(*DNSRegistry)
+
+// WithDNSResolver 设置解析使用的 net.Resolver，默认为 net.DefaultResolver
+func WithDNSResolver(r *net.Resolver) DNSOption {
+	return func(d *DNSRegistry) {
+		d.resolver = r
+	}
+}
+
+// WithDNSServer 向 addr(host:port) 上的 DNS 服务器发送查询，而不是系统配置的服务器
+func WithDNSServer(addr string) DNSOption {
+	return func(d *DNSRegistry) {
+		d.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, addr)
+			},
+		}
+	}
+}
+
+// WithDNSInterval 设置 Watch 重新解析的间隔，默认为 30 秒
+func WithDNSInterval(interval time.Duration) DNSOption {
+	return func(d *DNSRegistry) {
+		d.interval = interval
+	}
+}
+
+// WithDNSLogger 设置输出解析失败日志使用的 Logger，默认使用标准库 log
+func WithDNSLogger(l Logger) DNSOption {
+	return func(d *DNSRegistry) {
+		d.logger = l
+	}
+}
+
+// NewDNSRegistry 创建解析 name 的 A 记录的 DNSRegistry，节点地址为 ip:port
+func NewDNSRegistry(name string, port int, opts ...DNSOption) *DNSRegistry {
+	return newDNSRegistry(name, port, opts)
+}
+
+// NewDNSSRVRegistry 创建解析 name 的 SRV 记录的 DNSRegistry，例如 _grpc._tcp.gocache.default.svc.cluster.local
+func NewDNSSRVRegistry(name string, opts ...DNSOption) *DNSRegistry {
+	return newDNSRegistry(name, 0, opts)
+}
+
+func newDNSRegistry(name string, port int, opts []DNSOption) *DNSRegistry {
+	d := &DNSRegistry{
+		name:     name,
+		port:     port,
+		resolver: net.DefaultResolver,
+		interval: defaultDNSInterval,
+		logger:   stdLogger{},
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.interval <= 0 {
+		d.interval = defaultDNSInterval
+	}
+	return d
+}
+
+func (d *DNSRegistry) Register(ctx context.Context, service string, addr string) error {
+	return nil
+}
+
+func (d *DNSRegistry) Deregister(ctx context.Context, service string, addr string) error {
+	return nil
+}
+
+// Watch 每隔 interval 解析一次，节点列表变化时调用 onChange
+// 解析失败时保留上一次的节点列表，等待下一次解析
+func (d *DNSRegistry) Watch(ctx context.Context, service string, onChange func(addrs []string)) error {
+	var last []string
+	resolved := false
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		addrs, err := d.Resolve(ctx, service)
+		if err != nil && ctx.Err() == nil {
+			d.logger.Error("[registry] resolve dns failed", "name", d.name, "err", err)
+		} else if err == nil && (!resolved || !reflect.DeepEqual(addrs, last)) {
+			resolved, last = true, addrs
+			onChange(addrs)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Resolve 解析 DNS 记录并返回节点地址
+func (d *DNSRegistry) Resolve(ctx context.Context, service string) ([]string, error) {
+	peers := make(map[string]bool)
+	if d.port != 0 {
+		if err := d.lookupA(ctx, d.name, d.port, peers); err != nil {
+			return nil, err
+		}
+		return sortedAddrs(peers), nil
+	}
+
+	_, srvs, err := d.resolver.LookupSRV(ctx, "", "", d.name)
+	if err != nil {
+		return nil, fmt.Errorf("lookup srv %s failed: %v", d.name, err)
+	}
+	// 单个目标无法解析(例如记录尚未发布的 pod)时跳过它，全部目标都无法解析才返回错误
+	var lastErr error
+	for _, srv := range srvs {
+		if err := d.lookupA(ctx, srv.Target, int(srv.Port), peers); err != nil {
+			d.logger.Error("[registry] skip unresolvable srv target", "name", d.name, "target", srv.Target, "err", err)
+			lastErr = err
+		}
+	}
+	if len(peers) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return sortedAddrs(peers), nil
+}
+
+// lookupA 将 host 的每个 IPv4 与 IPv6 地址与 port 组成节点地址加入 peers，IPv6 地址形如 [::1]:port
+func (d *DNSRegistry) lookupA(ctx context.Context, host string, port int, peers map[string]bool) error {
+	ips, err := d.resolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("lookup %s failed: %v", host, err)
+	}
+	for _, ip := range ips {
+		peers[net.JoinHostPort(ip.String(), strconv.Itoa(port))] = true
+	}
+	return nil
+}
+
+var _ Registry = (*DNSRegistry)(nil)
@@ -0,0 +1,167 @@
+package registry
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubDNS 是只应答 A、AAAA 与 SRV 查询的本地 DNS 服务器
+type stubDNS struct {
+	mu   sync.Mutex
+	a    map[string][][4]byte
+	aaaa map[string][][16]byte
+	srv  map[string][]dnsmessage.SRVResource
+	conn net.PacketConn
+}
+
+func newStubDNS(t *testing.T) *stubDNS {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &stubDNS{a: make(map[string][][4]byte), aaaa: make(map[string][][16]byte), srv: make(map[string][]dnsmessage.SRVResource), conn: conn}
+	t.Cleanup(func() { conn.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *stubDNS) addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+func (s *stubDNS) setA(name string, ips ...[4]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.a[name] = ips
+}
+
+func (s *stubDNS) setAAAA(name string, ips ...[16]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aaaa[name] = ips
+}
+
+func (s *stubDNS) setSRV(name string, srvs ...dnsmessage.SRVResource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.srv[name] = srvs
+}
+
+func (s *stubDNS) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, from, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if resp, err := s.answer(buf[:n]); err == nil {
+			s.conn.WriteTo(resp, from)
+		}
+	}
+}
+
+func (s *stubDNS) answer(query []byte) ([]byte, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(query)
+	if err != nil {
+		return nil, err
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := q.Name.String()
+	rcode := dnsmessage.RCodeSuccess
+	if s.a[name] == nil && s.aaaa[name] == nil && s.srv[name] == nil {
+		rcode = dnsmessage.RCodeNameError
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: h.ID, Response: true, Authoritative: true, RCode: rcode})
+	b.StartQuestions()
+	b.Question(q)
+	b.StartAnswers()
+	rh := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET}
+	switch q.Type {
+	case dnsmessage.TypeA:
+		for _, ip := range s.a[name] {
+			b.AResource(rh, dnsmessage.AResource{A: ip})
+		}
+	case dnsmessage.TypeAAAA:
+		for _, ip := range s.aaaa[name] {
+			b.AAAAResource(rh, dnsmessage.AAAAResource{AAAA: ip})
+		}
+	case dnsmessage.TypeSRV:
+		for _, srv := range s.srv[name] {
+			b.SRVResource(rh, srv)
+		}
+	}
+	return b.Finish()
+}
+
+func TestDNSRegistryA(t *testing.T) {
+	dns := newStubDNS(t)
+	dns.setA("gocache.test.", [4]byte{10, 0, 0, 2}, [4]byte{10, 0, 0, 1})
+
+	r := NewDNSRegistry("gocache.test.", 6324, WithDNSServer(dns.addr()), WithDNSInterval(10*time.Millisecond))
+	addrs, err := r.Resolve(context.Background(), "gocache")
+	if err != nil || !reflect.DeepEqual(addrs, []string{"10.0.0.1:6324", "10.0.0.2:6324"}) {
+		t.Fatalf("unexpected addrs %v %v", addrs, err)
+	}
+
+	ch := watchAddrs(t, r, "gocache")
+	expectAddrs(t, ch, "10.0.0.1:6324", "10.0.0.2:6324")
+	dns.setA("gocache.test.", [4]byte{10, 0, 0, 1})
+	expectAddrs(t, ch, "10.0.0.1:6324")
+}
+
+func TestDNSRegistrySRV(t *testing.T) {
+	dns := newStubDNS(t)
+	dns.setSRV("_grpc._tcp.gocache.test.",
+		dnsmessage.SRVResource{Port: 9999, Target: dnsmessage.MustNewName("node1.gocache.test.")},
+		dnsmessage.SRVResource{Port: 9998, Target: dnsmessage.MustNewName("node2.gocache.test.")},
+	)
+	dns.setA("node1.gocache.test.", [4]byte{10, 0, 0, 1})
+	dns.setA("node2.gocache.test.", [4]byte{10, 0, 0, 2})
+
+	r := NewDNSSRVRegistry("_grpc._tcp.gocache.test.", WithDNSServer(dns.addr()))
+	addrs, err := r.Resolve(context.Background(), "gocache")
+	if err != nil || !reflect.DeepEqual(addrs, []string{"10.0.0.1:9999", "10.0.0.2:9998"}) {
+		t.Fatalf("unexpected addrs %v %v", addrs, err)
+	}
+	if _, err := NewDNSSRVRegistry("missing.gocache.test.", WithDNSServer(dns.addr())).Resolve(context.Background(), "gocache"); err == nil {
+		t.Fatalf("unknown name should fail")
+	}
+}
+
+// 测试 SRV 目标中有尚未发布地址的节点与只有 IPv6 地址的节点
+func TestDNSRegistrySRVPartial(t *testing.T) {
+	dns := newStubDNS(t)
+	dns.setSRV("_grpc._tcp.gocache.test.",
+		dnsmessage.SRVResource{Port: 9999, Target: dnsmessage.MustNewName("node1.gocache.test.")},
+		dnsmessage.SRVResource{Port: 9998, Target: dnsmessage.MustNewName("pending.gocache.test.")},
+		dnsmessage.SRVResource{Port: 9997, Target: dnsmessage.MustNewName("node6.gocache.test.")},
+	)
+	dns.setA("node1.gocache.test.", [4]byte{10, 0, 0, 1})
+	dns.setAAAA("node6.gocache.test.", [16]byte{0xfd, 15: 6})
+
+	r := NewDNSSRVRegistry("_grpc._tcp.gocache.test.", WithDNSServer(dns.addr()), WithDNSLogger(nopLogger{}))
+	addrs, err := r.Resolve(context.Background(), "gocache")
+	if err != nil || !reflect.DeepEqual(addrs, []string{"10.0.0.1:9999", "[fd00::6]:9997"}) {
+		t.Fatalf("target without a record should be skipped, got %v %v", addrs, err)
+	}
+
+	dns.setSRV("_grpc._tcp.gocache.test.",
+		dnsmessage.SRVResource{Port: 9998, Target: dnsmessage.MustNewName("pending.gocache.test.")},
+	)
+	if _, err := r.Resolve(context.Background(), "gocache"); err == nil {
+		t.Fatalf("resolve should fail when no target resolves")
+	}
+}
@@ -0,0 +1,321 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/naming/endpoints"
+)
+
+// EtcdRegistry 将节点以带租约的 key service/addr 注册到 etcd，节点的元数据保存在 endpoint 记录中
+// 节点停止心跳后经过 Config.LeaseTTL 秒，etcd 删除该 key，其他节点通过 Watch 得知
+type EtcdRegistry struct {
+	cfg    Config
+	mu     sync.Mutex
+	cli    *clientv3.Client      // 第一次使用时创建
+	leases map[string]*etcdLease // key 为 service/addr
+}
+
+// etcdLease 是一次注册使用的租约，续约中断后重新注册时 id 与 md 随之更新
+type etcdLease struct {
+	id     clientv3.LeaseID
+	md     Metadata           // 重新注册时写入的元数据
+	cancel context.CancelFunc // 停止续约
+}
+
+// 续约中断后重新注册的退避时间，首次立即重试，之后从 minRegisterBackoff 开始翻倍
+const (
+	minRegisterBackoff = time.Second
+	maxRegisterBackoff = 30 * time.Second
+)
+
+// NewEtcdRegistry 使用 cfg 创建 EtcdRegistry，第一次使用时才连接 etcd
+func NewEtcdRegistry(cfg Config) *EtcdRegistry {
+	return &EtcdRegistry{cfg: cfg, leases: make(map[string]*etcdLease)}
+}
+
+// client 返回 etcd 客户端，第一次调用时创建
+func (r *EtcdRegistry) client() (*clientv3.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cli == nil {
+		cli, err := clientv3.New(r.cfg.etcdConfig())
+		if err != nil {
+			return nil, fmt.Errorf("create etcd client failed: %v", err)
+		}
+		r.cli = cli
+	}
+	return r.cli, nil
+}
+
+// Register 创建租约并注册 addr，之后在后台续约直到 Deregister 或 Close
+func (r *EtcdRegistry) Register(ctx context.Context, service string, addr string) error {
+	return r.RegisterInstance(ctx, service, Instance{Addr: addr})
+}
+
+// RegisterInstance 与 Register 相同，但同时写入 ins.Metadata
+// addr 已经通过这个 EtcdRegistry 注册时沿用原有的租约，只更新元数据
+func (r *EtcdRegistry) RegisterInstance(ctx context.Context, service string, ins Instance) error {
+	cli, err := r.client()
+	if err != nil {
+		return err
+	}
+	addr := ins.Addr
+	key := service + "/" + addr
+	r.mu.Lock()
+	old := r.leases[key]
+	var oldID clientv3.LeaseID
+	if old != nil {
+		oldID = old.id
+		old.md = ins.Metadata
+	}
+	r.mu.Unlock()
+	if old != nil {
+		if err := etcdAdd(cli, oldID, service, addr, ins.Metadata); err != nil {
+			return fmt.Errorf("update etcd record failed: %v", err)
+		}
+		return nil
+	}
+
+	kctx, cancel := context.WithCancel(context.Background())
+	id, ch, err := r.grant(ctx, kctx, cli, service, ins)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	lease := &etcdLease{id: id, md: ins.Metadata, cancel: cancel}
+	r.mu.Lock()
+	if old := r.leases[key]; old != nil {
+		old.cancel()
+	}
+	r.leases[key] = lease
+	r.mu.Unlock()
+
+	// 续约中断时以新的租约重新注册，沿用最近一次写入的元数据
+	regrant := func(ctx context.Context) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+		r.mu.Lock()
+		md := lease.md
+		r.mu.Unlock()
+		id, ch, err := r.grant(ctx, ctx, cli, service, Instance{Addr: addr, Metadata: md})
+		if err != nil {
+			return nil, err
+		}
+		r.mu.Lock()
+		lease.id = id
+		r.mu.Unlock()
+		return ch, nil
+	}
+	logger := r.cfg.logger()
+	go keepAlive(kctx, ch, regrant, logger, service, addr)
+	logger.Info("[registry] register service ok", "service", service, "addr", addr)
+	return nil
+}
+
+// grant 创建租约并写入 ins，之后在 kctx 结束前持续续约
+func (r *EtcdRegistry) grant(ctx, kctx context.Context, cli *clientv3.Client, service string, ins Instance) (clientv3.LeaseID, <-chan *clientv3.LeaseKeepAliveResponse, error) {
+	resp, err := cli.Grant(ctx, r.cfg.leaseTTL())
+	if err != nil {
+		return 0, nil, fmt.Errorf("create lease failed: %v", err)
+	}
+	if err := etcdAdd(cli, resp.ID, service, ins.Addr, ins.Metadata); err != nil {
+		return 0, nil, fmt.Errorf("add etcd record failed: %v", err)
+	}
+	ch, err := cli.KeepAlive(kctx, resp.ID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("set keepalive failed: %v", err)
+	}
+	return resp.ID, ch, nil
+}
+
+// keepAlive 消费续约的回复直到 kctx 结束
+// 续约中断(租约已过期、与 etcd 断开等)时按指数退避调用 regrant 重新注册，直到成功或 kctx 结束；
+// 否则 etcd 中的记录过期后，节点虽然仍在服务，却会从所有节点的哈希环上消失
+func keepAlive(kctx context.Context, ch <-chan *clientv3.LeaseKeepAliveResponse,
+	regrant func(ctx context.Context) (<-chan *clientv3.LeaseKeepAliveResponse, error),
+	logger Logger, service string, addr string) {
+	for {
+		for range ch {
+			// 监听租约
+		}
+		if kctx.Err() != nil {
+			return
+		}
+		logger.Error("[registry] keep alive channel closed, register again", "service", service, "addr", addr)
+		var backoff time.Duration
+		for {
+			select {
+			case <-kctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			next, err := regrant(kctx)
+			if err == nil {
+				ch = next
+				logger.Info("[registry] register service again ok", "service", service, "addr", addr)
+				break
+			}
+			if kctx.Err() != nil {
+				return
+			}
+			logger.Error("[registry] register again failed", "service", service, "addr", addr, "err", err)
+			if backoff *= 2; backoff < minRegisterBackoff {
+				backoff = minRegisterBackoff
+			} else if backoff > maxRegisterBackoff {
+				backoff = maxRegisterBackoff
+			}
+		}
+	}
+}
+
+// Deregister 停止续约并撤销租约，etcd 随之删除 addr
+func (r *EtcdRegistry) Deregister(ctx context.Context, service string, addr string) error {
+	cli, err := r.client()
+	if err != nil {
+		return err
+	}
+	key := service + "/" + addr
+	r.mu.Lock()
+	lease := r.leases[key]
+	delete(r.leases, key)
+	var id clientv3.LeaseID
+	if lease != nil {
+		id = lease.id
+	}
+	r.mu.Unlock()
+
+	if lease == nil {
+		// 不是通过这个 EtcdRegistry 注册的，直接删除 key
+		em, err := endpoints.NewManager(cli, service)
+		if err != nil {
+			return err
+		}
+		return em.DeleteEndpoint(ctx, key)
+	}
+	lease.cancel()
+	_, err = cli.Revoke(ctx, id)
+	return err
+}
+
+// Watch 监听注册在 service 下的节点
+// etcd 中已有节点时，首次调用 onChange 传入的是当前全部节点
+func (r *EtcdRegistry) Watch(ctx context.Context, service string, onChange func(addrs []string)) error {
+	var last []string
+	return r.WatchInstances(ctx, service, func(instances []Instance) {
+		// 只有元数据变化时节点列表不变，不通知 onChange
+		if addrs := instanceAddrs(instances); last == nil || !reflect.DeepEqual(addrs, last) {
+			last = addrs
+			onChange(addrs)
+		}
+	})
+}
+
+// WatchInstances 监听注册在 service 下的节点及其元数据
+func (r *EtcdRegistry) WatchInstances(ctx context.Context, service string, onChange func(instances []Instance)) error {
+	cli, err := r.client()
+	if err != nil {
+		return err
+	}
+	em, err := endpoints.NewManager(cli, service)
+	if err != nil {
+		return err
+	}
+	ch, err := em.NewWatchChannel(ctx)
+	if err != nil {
+		return fmt.Errorf("watch %s failed: %v", service, err)
+	}
+
+	peers := make(map[string]Metadata)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case updates, ok := <-ch:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("watch %s closed", service)
+			}
+			if applyUpdates(peers, service, updates) {
+				onChange(sortedInstances(peers))
+			}
+		}
+	}
+}
+
+// Resolve 返回注册在 service 下的节点
+func (r *EtcdRegistry) Resolve(ctx context.Context, service string) ([]string, error) {
+	instances, err := r.ResolveInstances(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	return instanceAddrs(instances), nil
+}
+
+// ResolveInstances 返回注册在 service 下的节点及其元数据
+func (r *EtcdRegistry) ResolveInstances(ctx context.Context, service string) ([]Instance, error) {
+	cli, err := r.client()
+	if err != nil {
+		return nil, err
+	}
+	em, err := endpoints.NewManager(cli, service)
+	if err != nil {
+		return nil, err
+	}
+	eps, err := em.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list %s failed: %v", service, err)
+	}
+	peers := make(map[string]Metadata, len(eps))
+	for key, ep := range eps {
+		peers[strings.TrimPrefix(key, service+"/")] = decodeMetadata(ep.Metadata)
+	}
+	return sortedInstances(peers), nil
+}
+
+// Close 停止所有续约并关闭 etcd 客户端，租约会在过期后由 etcd 删除
+func (r *EtcdRegistry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, lease := range r.leases {
+		lease.cancel()
+		delete(r.leases, key)
+	}
+	if r.cli == nil {
+		return nil
+	}
+	err := r.cli.Close()
+	r.cli = nil
+	return err
+}
+
+// applyUpdates 将 updates 应用到 peers 上，返回节点列表或元数据是否发生变化
+// 删除事件不带 Endpoint，因此节点地址从 etcdAdd 写入的 key service/addr 中取得
+func applyUpdates(peers map[string]Metadata, service string, updates []*endpoints.Update) bool {
+	changed := false
+	for _, up := range updates {
+		addr := strings.TrimPrefix(up.Key, service+"/")
+		switch up.Op {
+		case endpoints.Add:
+			md := decodeMetadata(up.Endpoint.Metadata)
+			if old, ok := peers[addr]; !ok || !reflect.DeepEqual(old, md) {
+				peers[addr] = md
+				changed = true
+			}
+		case endpoints.Delete:
+			if _, ok := peers[addr]; ok {
+				delete(peers, addr)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+var _ MetadataRegistry = (*EtcdRegistry)(nil)
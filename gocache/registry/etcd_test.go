@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/naming/endpoints"
+)
+
+func TestApplyUpdates(t *testing.T) {
+	peers := make(map[string]Metadata)
+	changed := applyUpdates(peers, "gocache", []*endpoints.Update{
+		{Op: endpoints.Add, Key: "gocache/localhost:9999", Endpoint: endpoints.Endpoint{Addr: "localhost:9999"}},
+		{Op: endpoints.Add, Key: "gocache/localhost:9998", Endpoint: endpoints.Endpoint{Addr: "localhost:9998"}},
+	})
+	if !changed || !reflect.DeepEqual(instanceAddrs(sortedInstances(peers)), []string{"localhost:9998", "localhost:9999"}) {
+		t.Fatalf("unexpected peers %v", peers)
+	}
+
+	// 租约续期时 etcd 不会产生事件，但重复的 Add 不应被视为变化
+	if applyUpdates(peers, "gocache", []*endpoints.Update{{Op: endpoints.Add, Key: "gocache/localhost:9999"}}) {
+		t.Fatalf("duplicate add should not change peers")
+	}
+
+	changed = applyUpdates(peers, "gocache", []*endpoints.Update{{Op: endpoints.Delete, Key: "gocache/localhost:9998"}})
+	if !changed || !reflect.DeepEqual(instanceAddrs(sortedInstances(peers)), []string{"localhost:9999"}) {
+		t.Fatalf("expired peer should be removed, got %v", peers)
+	}
+}
+
+// 测试从 etcd 读回的元数据，watch 收到的 Metadata 是 JSON 解码后的 map
+func TestApplyUpdatesMetadata(t *testing.T) {
+	peers := map[string]Metadata{"localhost:9999": {}}
+	raw := map[string]interface{}{"weight": float64(4), "zone": "us-east-1a", "version": "v1.2.0", "features": []interface{}{"getmulti"}}
+	changed := applyUpdates(peers, "gocache", []*endpoints.Update{
+		{Op: endpoints.Add, Key: "gocache/localhost:9999", Endpoint: endpoints.Endpoint{Addr: "localhost:9999", Metadata: raw}},
+	})
+	want := Metadata{Weight: 4, Zone: "us-east-1a", Version: "v1.2.0", Features: []string{"getmulti"}}
+	if !changed || !reflect.DeepEqual(peers["localhost:9999"], want) {
+		t.Fatalf("metadata change should be applied, got %+v", peers["localhost:9999"])
+	}
+	if !want.HasFeature("getmulti") || want.HasFeature("stats") {
+		t.Fatalf("unexpected features %v", want.Features)
+	}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+// 测试续约中断后重新注册，直到 Deregister 或 Close 取消续约
+func TestKeepAliveRegrant(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan *clientv3.LeaseKeepAliveResponse)
+	regranted := make(chan chan *clientv3.LeaseKeepAliveResponse)
+	regrant := func(context.Context) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+		ch := make(chan *clientv3.LeaseKeepAliveResponse)
+		regranted <- ch
+		return ch, nil
+	}
+	done := make(chan struct{})
+	go func() {
+		keepAlive(ctx, first, regrant, nopLogger{}, "gocache", "localhost:9999")
+		close(done)
+	}()
+
+	next := func() chan *clientv3.LeaseKeepAliveResponse {
+		t.Helper()
+		select {
+		case ch := <-regranted:
+			return ch
+		case <-time.After(time.Second):
+			t.Fatal("lost lease should be granted again")
+			return nil
+		}
+	}
+	close(first) // 租约过期，续约中断
+	second := next()
+	close(second)
+	third := next()
+
+	cancel()
+	close(third)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("keepAlive should return once the registration is cancelled")
+	}
+}
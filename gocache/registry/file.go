@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// 检查文件是否变化的默认间隔
+const defaultPollInterval = 5 * time.Second
+
+// FileRegistry 从 JSON 或 YAML 文件中读取节点列表，文件内容为服务名到节点地址的映射，例如
+//
+//	{"gocache": ["10.0.0.1:6324", "10.0.0.2:6324"]}
+//
+// 后缀为 .yaml 或 .yml 的文件按 YAML 解析，其余按 JSON 解析
+// 节点列表由文件决定，Register 与 Deregister 不做任何事
+type FileRegistry struct {
+	path     string
+	interval time.Duration // Watch 检查文件是否变化的间隔
+}
+
+// NewFileRegistry 创建读取 path 的 FileRegistry，interval 为 0 时每 5 秒检查一次文件
+func NewFileRegistry(path string, interval time.Duration) *FileRegistry {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	return &FileRegistry{path: path, interval: interval}
+}
+
+func (r *FileRegistry) Register(ctx context.Context, service string, addr string) error {
+	return nil
+}
+
+func (r *FileRegistry) Deregister(ctx context.Context, service string, addr string) error {
+	return nil
+}
+
+// Watch 每隔 interval 检查一次文件的修改时间与大小，节点列表变化时调用 onChange
+// 文件暂时无法读取或解析时（例如正在被改写）保留上一次的节点列表
+func (r *FileRegistry) Watch(ctx context.Context, service string, onChange func(addrs []string)) error {
+	addrs, err := r.Resolve(ctx, service)
+	if err != nil {
+		return err
+	}
+	onChange(addrs)
+	modTime, size := r.stat()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		t, s := r.stat()
+		if t.Equal(modTime) && s == size {
+			continue
+		}
+		modTime, size = t, s
+		latest, err := r.Resolve(ctx, service)
+		if err != nil || reflect.DeepEqual(latest, addrs) {
+			continue
+		}
+		addrs = latest
+		onChange(addrs)
+	}
+}
+
+// Resolve 读取文件并返回 service 下的节点
+func (r *FileRegistry) Resolve(ctx context.Context, service string) ([]string, error) {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, err
+	}
+	services := make(map[string][]string)
+	switch filepath.Ext(r.path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &services)
+	default:
+		err = json.Unmarshal(data, &services)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s failed: %v", r.path, err)
+	}
+	addrs := append([]string{}, services[service]...)
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+// stat 返回文件的修改时间与大小，文件不存在时返回零值
+func (r *FileRegistry) stat() (time.Time, int64) {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return info.ModTime(), info.Size()
+}
+
+var _ Registry = (*FileRegistry)(nil)
@@ -0,0 +1,41 @@
+package registry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFileRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.json")
+	if err := os.WriteFile(path, []byte(`{"gocache": ["localhost:9999", "localhost:9998"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := NewFileRegistry(path, 10*time.Millisecond)
+	ch := watchAddrs(t, r, "gocache")
+	expectAddrs(t, ch, "localhost:9998", "localhost:9999")
+
+	// 写到一半的文件无法解析，保留上一次的节点列表
+	os.WriteFile(path, []byte(`{"gocache": [`), 0644)
+	time.Sleep(50 * time.Millisecond)
+	os.WriteFile(path, []byte(`{"gocache": ["localhost:9997"]}`), 0644)
+	expectAddrs(t, ch, "localhost:9997")
+}
+
+func TestFileRegistryYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.yaml")
+	data := "gocache:\n  - localhost:9999\n  - localhost:9998\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	addrs, err := NewFileRegistry(path, 0).Resolve(context.Background(), "gocache")
+	if err != nil || !reflect.DeepEqual(addrs, []string{"localhost:9998", "localhost:9999"}) {
+		t.Fatalf("unexpected addrs %v %v", addrs, err)
+	}
+	if _, err := NewFileRegistry(filepath.Join(t.TempDir(), "missing.yaml"), 0).Resolve(context.Background(), "gocache"); err == nil {
+		t.Fatalf("missing file should fail")
+	}
+}
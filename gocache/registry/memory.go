@@ -0,0 +1,111 @@
+package registry
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// MemoryRegistry 在进程内保存注册的节点，适合测试以及所有节点运行在同一个进程中的部署
+type MemoryRegistry struct {
+	mu       sync.Mutex
+	services map[string]map[string]Metadata    // service -> addr -> 元数据
+	watchers map[string]map[chan struct{}]bool // service -> 节点变化时收到通知的 channel
+}
+
+// NewMemoryRegistry 创建空的 MemoryRegistry
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		services: make(map[string]map[string]Metadata),
+		watchers: make(map[string]map[chan struct{}]bool),
+	}
+}
+
+func (r *MemoryRegistry) Register(ctx context.Context, service string, addr string) error {
+	return r.RegisterInstance(ctx, service, Instance{Addr: addr})
+}
+
+// RegisterInstance 注册 ins，addr 已注册时更新其元数据
+func (r *MemoryRegistry) RegisterInstance(ctx context.Context, service string, ins Instance) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.services[service] == nil {
+		r.services[service] = make(map[string]Metadata)
+	}
+	if old, ok := r.services[service][ins.Addr]; !ok || !reflect.DeepEqual(old, ins.Metadata) {
+		r.services[service][ins.Addr] = ins.Metadata
+		r.notify(service)
+	}
+	return nil
+}
+
+func (r *MemoryRegistry) Deregister(ctx context.Context, service string, addr string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.services[service][addr]; ok {
+		delete(r.services[service], addr)
+		r.notify(service)
+	}
+	return nil
+}
+
+// Watch 先以当前节点列表调用一次 onChange，之后每次变化时再调用
+func (r *MemoryRegistry) Watch(ctx context.Context, service string, onChange func(addrs []string)) error {
+	var last []string
+	return r.WatchInstances(ctx, service, func(instances []Instance) {
+		if addrs := instanceAddrs(instances); last == nil || !reflect.DeepEqual(addrs, last) {
+			last = addrs
+			onChange(addrs)
+		}
+	})
+}
+
+// WatchInstances 先以当前节点调用一次 onChange，之后节点或元数据每次变化时再调用
+func (r *MemoryRegistry) WatchInstances(ctx context.Context, service string, onChange func(instances []Instance)) error {
+	ch := make(chan struct{}, 1)
+	r.mu.Lock()
+	if r.watchers[service] == nil {
+		r.watchers[service] = make(map[chan struct{}]bool)
+	}
+	r.watchers[service][ch] = true
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.watchers[service], ch)
+		r.mu.Unlock()
+	}()
+
+	for {
+		instances, _ := r.ResolveInstances(ctx, service)
+		onChange(instances)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ch:
+		}
+	}
+}
+
+func (r *MemoryRegistry) Resolve(ctx context.Context, service string) ([]string, error) {
+	instances, _ := r.ResolveInstances(ctx, service)
+	return instanceAddrs(instances), nil
+}
+
+func (r *MemoryRegistry) ResolveInstances(ctx context.Context, service string) ([]Instance, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return sortedInstances(r.services[service]), nil
+}
+
+// notify 通知监听 service 的 Watch，调用前需持有 r.mu
+// channel 的容量为 1，Watch 来不及处理的多次变化会合并为一次
+func (r *MemoryRegistry) notify(service string) {
+	for ch := range r.watchers[service] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+var _ MetadataRegistry = (*MemoryRegistry)(nil)
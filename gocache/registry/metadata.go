@@ -0,0 +1,82 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+)
+
+// metadata模块定义节点注册时附带的元数据
+// 节点以元数据公布自己的容量、所在可用区、版本以及支持的 RPC，
+// 使不同规格的节点分到与容量成比例的 key，滚动升级时不向旧节点发送它不支持的请求
+
+// Metadata 是节点注册时附带的信息，etcd 中保存在 endpoint 记录的 Metadata 字段
+type Metadata struct {
+	Weight   int      `json:"weight,omitempty"`   // 容量权重，<=0 视为 1
+	Zone     string   `json:"zone,omitempty"`     // 可用区
+	Version  string   `json:"version,omitempty"`  // 节点的构建版本
+	Features []string `json:"features,omitempty"` // 节点支持的 RPC 功能
+}
+
+// HasFeature 返回节点是否公布了支持 feature
+func (md Metadata) HasFeature(feature string) bool {
+	for _, f := range md.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Instance 是一个注册的节点及其元数据
+type Instance struct {
+	Addr     string
+	Metadata Metadata
+}
+
+// MetadataRegistry 是能够保存节点元数据的 Registry
+// Registry 实现了它时，gocache 的 server 注册与监听节点都会带上元数据
+type MetadataRegistry interface {
+	Registry
+	// RegisterInstance 与 Register 相同，但同时保存 ins.Metadata，再次注册同一地址会更新元数据
+	RegisterInstance(ctx context.Context, service string, ins Instance) error
+	// WatchInstances 与 Watch 相同，但节点或其元数据变化时以按地址排序的完整列表调用 onChange
+	WatchInstances(ctx context.Context, service string, onChange func(instances []Instance)) error
+	// ResolveInstances 返回 service 下当前的节点及其元数据，按地址排序
+	ResolveInstances(ctx context.Context, service string) ([]Instance, error)
+}
+
+// decodeMetadata 还原 endpoint 记录中的元数据
+// etcd 的 endpoints 以 JSON 保存 Metadata，读回时是 map[string]interface{}，因此重新编码一次再解码
+func decodeMetadata(v interface{}) Metadata {
+	var md Metadata
+	switch v := v.(type) {
+	case nil:
+	case Metadata:
+		md = v
+	default:
+		if data, err := json.Marshal(v); err == nil {
+			json.Unmarshal(data, &md)
+		}
+	}
+	return md
+}
+
+// sortedInstances 返回按地址排序的节点列表
+func sortedInstances(instances map[string]Metadata) []Instance {
+	list := make([]Instance, 0, len(instances))
+	for addr, md := range instances {
+		list = append(list, Instance{Addr: addr, Metadata: md})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Addr < list[j].Addr })
+	return list
+}
+
+// instanceAddrs 返回节点列表中的地址
+func instanceAddrs(instances []Instance) []string {
+	addrs := make([]string, 0, len(instances))
+	for _, ins := range instances {
+		addrs = append(addrs, ins.Addr)
+	}
+	return addrs
+}
@@ -22,33 +22,91 @@ var (
 	}
 )
 
-// etcdAdd 在租赁模式添加一对kv至etcd
-func etcdAdd(c *clientv3.Client, lid clientv3.LeaseID, service string, addr string) error {
+// 租约默认 5 秒过期
+const defaultLeaseTTL = 5
+
+// Logger 是 Register 输出日志使用的接口，gocache.Logger 与 *slog.Logger 都实现了它
+type Logger interface {
+	Info(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// Config 是注册服务使用的配置，零值代表使用默认配置
+type Config struct {
+	// Etcd 为 etcd 客户端的配置，包括 endpoints、TLS 与用户名密码
+	// Endpoints 为空时使用 localhost:2379
+	Etcd clientv3.Config
+	// LeaseTTL 为租约的过期时间（秒），服务停止心跳后经过该时间从 etcd 中删除，<=0 时为 5 秒
+	LeaseTTL int64
+	// Logger 为 nil 时使用标准库 log
+	Logger Logger
+}
+
+// etcdConfig 返回填充了默认值的 etcd 客户端配置
+func (cfg Config) etcdConfig() clientv3.Config {
+	etcd := cfg.Etcd
+	if len(etcd.Endpoints) == 0 {
+		etcd.Endpoints = defaultEtcdConfig.Endpoints
+	}
+	if etcd.DialTimeout == 0 {
+		etcd.DialTimeout = defaultEtcdConfig.DialTimeout
+	}
+	return etcd
+}
+
+func (cfg Config) leaseTTL() int64 {
+	if cfg.LeaseTTL <= 0 {
+		return defaultLeaseTTL
+	}
+	return cfg.LeaseTTL
+}
+
+func (cfg Config) logger() Logger {
+	if cfg.Logger == nil {
+		return stdLogger{}
+	}
+	return cfg.Logger
+}
+
+// stdLogger 使用标准库 log 输出日志
+type stdLogger struct{}
+
+func (stdLogger) Info(msg string, args ...interface{})  { log.Println(append([]interface{}{msg}, args...)...) }
+func (stdLogger) Error(msg string, args ...interface{}) { log.Println(append([]interface{}{msg}, args...)...) }
+
+// etcdAdd 在租赁模式添加一对kv至etcd，md 保存在 endpoint 记录的 Metadata 中
+func etcdAdd(c *clientv3.Client, lid clientv3.LeaseID, service string, addr string, md Metadata) error {
 	em, err := endpoints.NewManager(c, service)
 	if err != nil {
 		return err
 	}
 	//return em.AddEndpoint(c.Ctx(), service+"/"+addr, endpoints.Endpoint{Addr: addr})
-	return em.AddEndpoint(c.Ctx(), service+"/"+addr, endpoints.Endpoint{Addr: addr}, clientv3.WithLease(lid))
+	return em.AddEndpoint(c.Ctx(), service+"/"+addr, endpoints.Endpoint{Addr: addr, Metadata: md}, clientv3.WithLease(lid))
 }
 
-// Register 注册一个服务至etcd
+// Register 使用默认配置注册一个服务至etcd
 // 注意 Register将不会return 如果没有error的话
 func Register(service string, addr string, stop chan error) error {
+	return RegisterWithConfig(Config{}, service, addr, stop)
+}
+
+// RegisterWithConfig 与 Register 相同，但使用 cfg 中的 etcd 配置、租约时间与 Logger
+func RegisterWithConfig(cfg Config, service string, addr string, stop chan error) error {
+	logger := cfg.logger()
 	// 创建一个etcd client
-	cli, err := clientv3.New(defaultEtcdConfig)
+	cli, err := clientv3.New(cfg.etcdConfig())
 	if err != nil {
 		return fmt.Errorf("create etcd client failed: %v", err)
 	}
 	defer cli.Close()
-	// 创建一个租约 配置5秒过期
-	resp, err := cli.Grant(context.Background(), 5)
+	// 创建一个租约 配置cfg.LeaseTTL秒过期
+	resp, err := cli.Grant(context.Background(), cfg.leaseTTL())
 	if err != nil {
 		return fmt.Errorf("create lease failed: %v", err)
 	}
 	leaseId := resp.ID
 	// 注册服务
-	err = etcdAdd(cli, leaseId, service, addr)
+	err = etcdAdd(cli, leaseId, service, addr, Metadata{})
 	if err != nil {
 		return fmt.Errorf("add etcd record failed: %v", err)
 	}
@@ -58,21 +116,21 @@ func Register(service string, addr string, stop chan error) error {
 		return fmt.Errorf("set keepalive failed: %v", err)
 	}
 
-	log.Printf("[%s] register service ok\n", addr)
+	logger.Info("[registry] register service ok", "service", service, "addr", addr)
 	for {
 		select {
 		case err := <-stop:
 			if err != nil {
-				log.Println(err)
+				logger.Error("[registry] service stopped", "addr", addr, "err", err)
 			}
 			return err
 		case <-cli.Ctx().Done():
-			log.Println("service closed")
+			logger.Info("[registry] service closed", "addr", addr)
 			return nil
 		case _, ok := <-ch:
 			// 监听租约
 			if !ok {
-				log.Println("keep alive channel closed")
+				logger.Error("[registry] keep alive channel closed", "addr", addr)
 				_, err := cli.Revoke(context.Background(), leaseId)
 				return err
 			}
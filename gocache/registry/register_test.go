@@ -21,8 +21,22 @@ func TestEtcdAdd(t *testing.T) {
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
-	err = etcdAdd(cli, resp.ID, "test", "127.0.0.1:6324")
+	err = etcdAdd(cli, resp.ID, "test", "127.0.0.1:6324", Metadata{})
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
-}
\ No newline at end of file
+}
+
+func TestConfigDefaults(t *testing.T) {
+	var cfg Config
+	if etcd := cfg.etcdConfig(); etcd.Endpoints[0] != "localhost:2379" || etcd.DialTimeout != 5*time.Second {
+		t.Fatalf("unexpected default etcd config %v", etcd)
+	}
+	if cfg.leaseTTL() != 5 {
+		t.Fatalf("default lease ttl should be 5s")
+	}
+	cfg = Config{Etcd: clientv3.Config{Endpoints: []string{"10.0.0.1:2379"}}, LeaseTTL: 30}
+	if cfg.etcdConfig().Endpoints[0] != "10.0.0.1:2379" || cfg.leaseTTL() != 30 {
+		t.Fatalf("config should override the defaults")
+	}
+}
@@ -0,0 +1,101 @@
+package registry
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
+)
+
+// registry模块定义服务发现的接口，以及基于该接口的 gRPC 解析器
+// 除 etcd 外还提供了静态列表、文件与进程内三种实现
+
+// Registry 是服务发现的接口，实现需要是并发安全的
+type Registry interface {
+	// Register 将 addr 注册到 service 下，注册会一直保持到 Deregister 为止
+	// ctx 只限制注册本身的耗时
+	Register(ctx context.Context, service string, addr string) error
+	// Deregister 将 addr 从 service 下删除
+	Deregister(ctx context.Context, service string, addr string) error
+	// Watch 监听 service 下的节点，节点变化时以排好序的完整节点列表调用 onChange
+	// 注意 Watch 在 ctx 结束前不会 return，除非出错
+	Watch(ctx context.Context, service string, onChange func(addrs []string)) error
+	// Resolve 返回 service 下当前的节点，按地址排序
+	Resolve(ctx context.Context, service string) ([]string, error)
+}
+
+// 通过 Registry 解析地址的 gRPC scheme
+const scheme = "gocache"
+
+// Dial 通过 r 解析 target 并建立 gRPC 连接，opts 会追加到默认的 DialOption 之后
+// target 形如 service/addr 时，只有 addr 注册在 service 下时连接才可用；
+// target 只有 service 时，连接会在 service 下的所有节点之间轮流发送请求
+func Dial(r Registry, target string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithResolvers(&resolverBuilder{registry: r}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin":{}}]}`),
+	}, opts...)
+	return grpc.Dial(scheme+":///"+target, opts...)
+}
+
+// resolverBuilder 基于 Registry.Watch 实现 gRPC 的 resolver.Builder
+type resolverBuilder struct {
+	registry Registry
+}
+
+func (b *resolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	service, addr := splitTarget(target.Endpoint())
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		err := b.registry.Watch(ctx, service, func(addrs []string) {
+			var state resolver.State
+			for _, a := range addrs {
+				if addr == "" || a == addr {
+					state.Addresses = append(state.Addresses, resolver.Address{Addr: a})
+				}
+			}
+			cc.UpdateState(state)
+		})
+		if err != nil && ctx.Err() == nil {
+			cc.ReportError(err)
+		}
+	}()
+	return &watchResolver{cancel: cancel}, nil
+}
+
+func (b *resolverBuilder) Scheme() string {
+	return scheme
+}
+
+// watchResolver 在连接关闭时停止 Watch
+type watchResolver struct {
+	cancel context.CancelFunc
+}
+
+func (r *watchResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (r *watchResolver) Close() {
+	r.cancel()
+}
+
+// splitTarget 将 service/addr 拆分为 service 与 addr，addr 需要是 host:port 的格式
+func splitTarget(target string) (service string, addr string) {
+	i := strings.LastIndex(target, "/")
+	if i < 0 || !strings.Contains(target[i+1:], ":") {
+		return target, ""
+	}
+	return target[:i], target[i+1:]
+}
+
+func sortedAddrs(peers map[string]bool) []string {
+	addrs := make([]string, 0, len(peers))
+	for addr := range peers {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
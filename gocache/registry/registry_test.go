@@ -0,0 +1,78 @@
+package registry
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// watchAddrs 在后台运行 Watch，将每次回调的节点列表发送到返回的 channel
+func watchAddrs(t *testing.T, r Registry, service string) <-chan []string {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	ch := make(chan []string, 16)
+	go r.Watch(ctx, service, func(addrs []string) { ch <- addrs })
+	return ch
+}
+
+// expectAddrs 等待 Watch 回调 want
+func expectAddrs(t *testing.T, ch <-chan []string, want ...string) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		if len(got) != 0 || len(want) != 0 {
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("expected %v, got %v", want, got)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %v", want)
+	}
+}
+
+func TestMemoryRegistry(t *testing.T) {
+	r := NewMemoryRegistry()
+	ctx := context.Background()
+	ch := watchAddrs(t, r, "gocache")
+	expectAddrs(t, ch)
+
+	r.Register(ctx, "gocache", "localhost:9999")
+	expectAddrs(t, ch, "localhost:9999")
+	r.Register(ctx, "gocache", "localhost:9998")
+	expectAddrs(t, ch, "localhost:9998", "localhost:9999")
+	r.Register(ctx, "other", "localhost:9997")
+
+	if addrs, _ := r.Resolve(ctx, "gocache"); !reflect.DeepEqual(addrs, []string{"localhost:9998", "localhost:9999"}) {
+		t.Fatalf("unexpected addrs %v", addrs)
+	}
+	r.Deregister(ctx, "gocache", "localhost:9999")
+	expectAddrs(t, ch, "localhost:9998")
+}
+
+func TestStaticRegistry(t *testing.T) {
+	r := NewStaticRegistry("gocache", "localhost:9999", "localhost:9998")
+	ctx := context.Background()
+	r.Register(ctx, "gocache", "localhost:9997")
+	if addrs, _ := r.Resolve(ctx, "gocache"); !reflect.DeepEqual(addrs, []string{"localhost:9998", "localhost:9999"}) {
+		t.Fatalf("unexpected addrs %v", addrs)
+	}
+	if addrs, _ := r.Resolve(ctx, "other"); len(addrs) != 0 {
+		t.Fatalf("unknown service should have no addrs, got %v", addrs)
+	}
+	expectAddrs(t, watchAddrs(t, r, "gocache"), "localhost:9998", "localhost:9999")
+}
+
+func TestSplitTarget(t *testing.T) {
+	cases := map[string][2]string{
+		"gocache/localhost:9999": {"gocache", "localhost:9999"},
+		"cluster/a/10.0.0.1:80":  {"cluster/a", "10.0.0.1:80"},
+		"gocache":                {"gocache", ""},
+		"cluster/a":              {"cluster/a", ""},
+	}
+	for target, want := range cases {
+		if service, addr := splitTarget(target); service != want[0] || addr != want[1] {
+			t.Fatalf("splitTarget(%q) = %q %q, want %v", target, service, addr, want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package registry
+
+import (
+	"context"
+	"sort"
+)
+
+// StaticRegistry 使用固定的节点列表，适合节点不会变化的部署
+// 节点列表由创建时决定，Register 与 Deregister 不做任何事
+type StaticRegistry struct {
+	service string
+	addrs   []string
+}
+
+// NewStaticRegistry 创建 service 下只有 addrs 这些节点的 StaticRegistry
+func NewStaticRegistry(service string, addrs ...string) *StaticRegistry {
+	sorted := append([]string(nil), addrs...)
+	sort.Strings(sorted)
+	return &StaticRegistry{service: service, addrs: sorted}
+}
+
+func (r *StaticRegistry) Register(ctx context.Context, service string, addr string) error {
+	return nil
+}
+
+func (r *StaticRegistry) Deregister(ctx context.Context, service string, addr string) error {
+	return nil
+}
+
+// Watch 以节点列表调用一次 onChange，之后等待 ctx 结束
+func (r *StaticRegistry) Watch(ctx context.Context, service string, onChange func(addrs []string)) error {
+	addrs, _ := r.Resolve(ctx, service)
+	onChange(addrs)
+	<-ctx.Done()
+	return nil
+}
+
+func (r *StaticRegistry) Resolve(ctx context.Context, service string) ([]string, error) {
+	if service != r.service {
+		return nil, nil
+	}
+	return append([]string(nil), r.addrs...), nil
+}
+
+var _ Registry = (*StaticRegistry)(nil)
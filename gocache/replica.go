@@ -0,0 +1,97 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// replica模块实现多副本下的节点选择
+// key 的前 n 个节点互为副本，所属节点不可用时 Pick 依次尝试其余副本，而不是直接回退到本地加载
+
+// replicaPeer 按顺序访问 key 的副本节点，前一个节点不可用时尝试下一个
+// 发往非所属节点的请求带上 redirectHeader，收到请求的副本只在本地查找或加载，不会再转发回不可用的所属节点
+// 地址以逗号拼接保存，使它是可比较的值类型，GetMulti 仍能合并副本相同的 key
+type replicaPeer struct {
+	s     *server
+	addrs string // 按优先级排列的副本地址，以逗号分隔
+}
+
+func (p replicaPeer) Fetch(group string, key string) ([]byte, error) {
+	v, err := p.FetchContext(context.Background(), group, key)
+	if err != nil {
+		return nil, err
+	}
+	return v.b, nil
+}
+
+func (p replicaPeer) FetchContext(ctx context.Context, group string, key string) (ByteView, error) {
+	var errs []error
+	for _, r := range p.clients() {
+		c := r.client
+		v, err := c.FetchContext(p.outgoing(ctx, r.pos), group, key)
+		if err == nil {
+			return v, nil
+		}
+		// key 不存在、已超时或被取消时其他副本也只会得到同样的结果
+		if errors.Is(err, ErrNotFound) || ctx.Err() != nil {
+			return ByteView{}, err
+		}
+		p.s.logger().Warn("[gocache_svr] replica unavailable, try the next one", "addr", p.s.addr, "key", key, "peer", c.name, "err", err)
+		errs = append(errs, err)
+	}
+	return ByteView{}, errors.Join(errs...)
+}
+
+// FetchMulti 整个请求失败时尝试下一个副本，单个 key 的错误直接返回
+func (p replicaPeer) FetchMulti(ctx context.Context, group string, keys []string) (map[string]ByteView, map[string]error, error) {
+	var errs []error
+	for _, r := range p.clients() {
+		c := r.client
+		values, keyErrs, err := c.FetchMulti(p.outgoing(ctx, r.pos), group, keys)
+		if err == nil || ctx.Err() != nil {
+			return values, keyErrs, err
+		}
+		p.s.logger().Warn("[gocache_svr] replica unavailable, try the next one", "addr", p.s.addr, "keys", len(keys), "peer", c.name, "err", err)
+		errs = append(errs, err)
+	}
+	return nil, nil, errors.Join(errs...)
+}
+
+// String 返回副本节点的地址
+func (p replicaPeer) String() string {
+	return "replicas(" + p.addrs + ")"
+}
+
+// replica 是一个副本的 client 及其在 addrs 中的位置
+type replica struct {
+	client *client
+	pos    int // 0 为所属节点
+}
+
+// clients 返回副本的 client，已被移除的节点被跳过，其余副本保留原来的位置
+func (p replicaPeer) clients() []replica {
+	p.s.mu.Lock()
+	defer p.s.mu.Unlock()
+	var replicas []replica
+	for i, addr := range strings.Split(p.addrs, ",") {
+		if c, ok := p.s.clients[addr]; ok {
+			replicas = append(replicas, replica{client: c, pos: i})
+		}
+	}
+	return replicas
+}
+
+// outgoing 为发往位置 pos 的副本的请求带上 redirectHeader，pos 为 0 时是所属节点，不需要标记
+// 所属节点刚被移除时，第一个可用的副本仍需要标记，否则它可能按过时的哈希环再次转发
+func (p replicaPeer) outgoing(ctx context.Context, pos int) context.Context {
+	if pos == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, redirectHeader, "1")
+}
+
+var _ ContextFetcher = replicaPeer{}
+var _ BatchFetcher = replicaPeer{}
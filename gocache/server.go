@@ -6,18 +6,24 @@ package gocache
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"gocache/consistenthash"
 	pb "gocache/gocachepb"
 	"gocache/registry"
-	"log"
+	"io"
 	"net"
+	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // server 模块为gocache之间提供通信能力
@@ -27,10 +33,27 @@ import (
 //
 // 服务器的默认地址
 const (
-	defaultAddr     = "127.0.0.1:6324"
-	defaultReplicas = 50
+	defaultAddr       = "127.0.0.1:6324"
+	defaultReplicas   = 50
+	defaultPrefix     = "gocache"
+	defaultRPCTimeout = 10 * time.Second
+	defaultLeaseTTL   = 5 * time.Second
 )
 
+// Version 是 server 注册时公布的构建版本，可以在编译时通过 -ldflags "-X gocache.Version=v1.2.3" 设置
+var Version = "dev"
+
+// server 在注册信息中公布支持的 RPC 功能，client 不会向没有公布某项功能的节点发送对应的请求，
+// 滚动升级时新功能只在所有节点都支持后才会被使用
+const (
+	FeatureGetMulti = "getmulti" // GetMulti 批量获取
+	FeatureUpdate   = "update"   // Set、Remove 与 Invalidate
+	FeatureStats    = "stats"    // Stats 统计数据
+)
+
+// features 是当前版本的 server 支持的全部功能
+var features = []string{FeatureGetMulti, FeatureUpdate, FeatureStats}
+
 // 配置了 etcd 客户端的默认设置，包括 etcd 服务的端点地址和拨号超时时间。这是用于服务发现和注册的配置，确保服务器可以与 etcd 集群正确通信。
 var (
 	defaultEtcdConfig = clientv3.Config{
@@ -47,19 +70,287 @@ type server struct {
 	status     bool       // true: running false: stop
 	stopSignal chan error // 通知registry revoke服务
 	mu         sync.Mutex
-	consHash   *consistenthash.Consistency
-	clients    map[string]*client //每个客户端地址对应一个客户端实例
+	consHash   consistenthash.Placement
+	clients    map[string]*client           //每个客户端地址对应一个客户端实例
+	peerMeta   map[string]registry.Metadata // 从 Registry 读回的节点元数据，Registry 不支持元数据时为空
+	pool       *connPool                    // client 共用的 gRPC 连接，每个远端节点一个
+	config     serverConfig
+	stopWatch  context.CancelFunc // 停止监听 etcd 中的节点变化
+	watchDone  chan struct{}      // 监听节点变化的 goroutine 退出时关闭
+	stopped    bool               // Stop 之后为 true，仍在进行的节点变化回调不再重建哈希环与连接，再次 Start 时清除
+
+	metricsAddr    string       // 暴露监控指标的 HTTP 地址，为空代表不启动
+	metricsHandler http.Handler // 例如 gocache/metrics 的 Handler()
+	metricsServer  *http.Server
+
+	log Logger // 为 nil 时使用 slog.Default()，同时用于该 server 创建的 client
+}
+
+// serverConfig 是 server 的可配置项，不同的 server 可以连接不同的 etcd 集群
+type serverConfig struct {
+	etcd        clientv3.Config         // etcd 的 endpoints、TLS 与认证信息
+	prefix      string                  // 注册到 etcd 的服务名前缀
+	replicas    int                     // 一致性哈希中每个节点的虚拟节点数
+	hash        consistenthash.HashFunc // 为 nil 时使用 crc32.ChecksumIEEE
+	grpcOptions []grpc.ServerOption     // 创建 gRPC 服务时追加的选项
+	dialOptions []grpc.DialOption       // client 连接远端节点时追加的选项
+	rpcTimeout  time.Duration           // ctx 没有 deadline 时访问远端节点的超时时间
+	leaseTTL    time.Duration           // etcd 租约的过期时间
+
+	registry      registry.Registry               // 注册与发现节点，默认使用 etcd
+	ownRegistry   bool                            // registry 由 NewServer 创建，注销后随 server 停止而关闭
+	directDial    bool                            // client 直接连接节点地址，不通过 registry 解析
+	metadata      registry.Metadata               // 注册时公布的元数据
+	loadFactor    float64                         // 有界负载的系数，0 代表不限制
+	placement     func() consistenthash.Placement // 创建放置策略，为 nil 时使用一致性哈希环
+	replication   int                             // 每个 key 的副本数，<= 1 代表只有所属节点
+	replicaWrites bool                            // Set 与 Remove 是否发送给所有副本
+	watchPeers    bool                            // 是否根据 etcd 中注册的节点维护哈希环
+	onPeersChange func(added, removed []string)   // 哈希环上的节点变化后调用
+}
+
+// ServerOption 用于配置 NewServer 创建的 server
+type ServerOption func(*server)
+
+// WithEtcdConfig 设置连接 etcd 使用的完整配置，包括 endpoints、TLS 与用户名密码
+func WithEtcdConfig(cfg clientv3.Config) ServerOption {
+	return func(s *server) {
+		s.config.etcd = cfg
+	}
+}
+
+// WithEtcdEndpoints 设置 etcd 的地址，默认为 localhost:2379
+func WithEtcdEndpoints(endpoints ...string) ServerOption {
+	return func(s *server) {
+		s.config.etcd.Endpoints = endpoints
+	}
+}
+
+// WithEtcdTLS 使用 TLS 连接 etcd
+func WithEtcdTLS(cfg *tls.Config) ServerOption {
+	return func(s *server) {
+		s.config.etcd.TLS = cfg
+	}
+}
+
+// WithEtcdAuth 设置连接 etcd 的用户名与密码
+func WithEtcdAuth(username, password string) ServerOption {
+	return func(s *server) {
+		s.config.etcd.Username, s.config.etcd.Password = username, password
+	}
+}
+
+// WithServicePrefix 设置注册到 etcd 的服务名前缀，默认为 "gocache"
+// 共用一个 etcd 集群的多个缓存集群需要使用不同的前缀
+func WithServicePrefix(prefix string) ServerOption {
+	return func(s *server) {
+		s.config.prefix = prefix
+	}
+}
+
+// WithReplicas 设置一致性哈希中每个节点的虚拟节点数，默认为 50，使用 WithPlacement 时无效
+func WithReplicas(n int) ServerOption {
+	return func(s *server) {
+		s.config.replicas = n
+	}
+}
+
+// WithHash 设置一致性哈希使用的哈希函数，默认为 crc32.ChecksumIEEE，使用 WithPlacement 时无效
+func WithHash(fn consistenthash.HashFunc) ServerOption {
+	return func(s *server) {
+		s.config.hash = fn
+	}
+}
+
+// WithGRPCServerOptions 设置创建 gRPC 服务时追加的选项，例如 TLS 证书
+func WithGRPCServerOptions(opts ...grpc.ServerOption) ServerOption {
+	return func(s *server) {
+		s.config.grpcOptions = append(s.config.grpcOptions, opts...)
+	}
+}
+
+// WithDialOptions 设置连接远端节点时追加的 gRPC 选项，例如传输层凭证
+func WithDialOptions(opts ...grpc.DialOption) ServerOption {
+	return func(s *server) {
+		s.config.dialOptions = append(s.config.dialOptions, opts...)
+	}
+}
+
+// WithRPCTimeout 设置 ctx 没有 deadline 时访问远端节点的超时时间，默认为 10 秒
+func WithRPCTimeout(d time.Duration) ServerOption {
+	return func(s *server) {
+		s.config.rpcTimeout = d
+	}
+}
+
+// WithLeaseTTL 设置 etcd 租约的过期时间，节点停止心跳后经过该时间被移出服务列表，默认为 5 秒
+// etcd 的租约以秒为单位，不足一秒的部分向上取整
+func WithLeaseTTL(d time.Duration) ServerOption {
+	return func(s *server) {
+		s.config.leaseTTL = d
+	}
+}
+
+// WithRegistry 设置注册与发现节点使用的 Registry，默认为使用 etcd 配置的 registry.EtcdRegistry
+// 设置后 WithEtcdConfig 等 etcd 相关的选项不再生效
+func WithRegistry(r registry.Registry) ServerOption {
+	return func(s *server) {
+		s.config.registry = r
+	}
+}
+
+// WithDirectDial 让 client 直接以 gRPC 连接远端节点的地址，不再通过 Registry 解析 service/addr
+// 配合 WithRegistry(registry.NewStaticRegistry(prefix)) 与 SetPeers 使用时完全不需要 etcd
+func WithDirectDial() ServerOption {
+	return func(s *server) {
+		s.config.directDial = true
+	}
+}
+
+// WithNodeMetadata 设置注册时公布的元数据，例如容量权重与可用区
+// Version 与 Features 为空时使用 gocache.Version 与当前版本支持的全部功能；Registry 实现了 registry.MetadataRegistry 时才会保存
+func WithNodeMetadata(md registry.Metadata) ServerOption {
+	return func(s *server) {
+		s.config.metadata = md
+	}
+}
+
+// WithBoundedLoad 开启有界负载的一致性哈希：本节点发往某个远端节点的进行中请求超过平均值的 factor 倍(例如 1.25)时，
+// Pick 顺时针选择下一个节点，避免少数热点 key 压垮一个节点。负载在请求完成时上报，本节点自身的负载不计入；
+// 收到转发请求的节点只在本地查找或加载，Set 与 Remove 仍发送给 key 所属的节点
+func WithBoundedLoad(factor float64) ServerOption {
+	return func(s *server) {
+		s.config.loadFactor = factor
+	}
+}
+
+// WithPlacement 设置 key 到节点的放置策略，newPlacement 在每次重建节点列表时调用，
+// 例如 consistenthash.NewRendezvous。默认使用一致性哈希环。
+// 策略实现了 consistenthash.Weighted 时按元数据中的权重分配 key，实现了 consistenthash.Bounded 时 WithBoundedLoad 才生效
+func WithPlacement(newPlacement func() consistenthash.Placement) ServerOption {
+	return func(s *server) {
+		s.config.placement = newPlacement
+	}
+}
+
+// WithReplication 让每个 key 有 n 个副本节点：从所属节点开始沿哈希环顺时针的 n 个不同节点，
+// 所属节点不可用时 Pick 依次尝试其余副本，而不是直接回退到本地加载、把压力转移到数据源。
+// 放置策略需要实现 consistenthash.Replicated；开启有界负载时 Pick 按负载选择节点，不再尝试副本
+func WithReplication(n int) ServerOption {
+	return func(s *server) {
+		s.config.replication = n
+	}
+}
+
+// WithReplicatedWrites 让 Group.Set 与 Group.Remove 写入 key 的所有副本，需要与 WithReplication 一起使用
+// 某个副本写入失败时仍会写入其余副本，并返回所有失败；Invalidate 本就通知所有节点，不受影响
+func WithReplicatedWrites() ServerOption {
+	return func(s *server) {
+		s.config.replicaWrites = true
+	}
+}
+
+// WithPeerWatch 让 server 在 Start 后监听 Registry 中以服务名前缀注册的节点，
+// 节点注册或租约过期时相应地在哈希环上添加、删除节点，并创建或关闭对应的 client。
+// onChange 不为 nil 时在每次变化后以新增和删除的节点地址调用
+func WithPeerWatch(onChange func(added, removed []string)) ServerOption {
+	return func(s *server) {
+		s.config.watchPeers = true
+		s.config.onPeersChange = onChange
+	}
+}
+
+// WithServerLogger 设置 server 及其访问远端节点的 client 输出日志使用的 Logger
+func WithServerLogger(l Logger) ServerOption {
+	return func(s *server) {
+		s.log = l
+	}
 }
 
 // NewServer 创建cache的svr 若addr为空 则使用defaultAddr
-func NewServer(addr string) (*server, error) {
+func NewServer(addr string, opts ...ServerOption) (*server, error) {
 	if addr == "" {
 		addr = defaultAddr
 	}
 	if !validPeerAddr(addr) {
 		return nil, fmt.Errorf("invalid addr %s, it should be x.x.x.x:port", addr)
 	}
-	return &server{addr: addr}, nil
+	s := &server{
+		addr: addr,
+		pool: newConnPool(),
+		config: serverConfig{
+			etcd:       defaultEtcdConfig,
+			prefix:     defaultPrefix,
+			replicas:   defaultReplicas,
+			rpcTimeout: defaultRPCTimeout,
+			leaseTTL:   defaultLeaseTTL,
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.config.replicas <= 0 {
+		return nil, fmt.Errorf("invalid replicas %d, it should be positive", s.config.replicas)
+	}
+	if s.config.registry == nil {
+		s.config.registry = registry.NewEtcdRegistry(s.registryConfig())
+		s.config.ownRegistry = true
+	}
+	return s, nil
+}
+
+// registryConfig 返回向 etcd 注册服务使用的配置
+func (s *server) registryConfig() registry.Config {
+	return registry.Config{
+		Etcd:     s.config.etcd,
+		LeaseTTL: int64((s.config.leaseTTL + time.Second - 1) / time.Second),
+		Logger:   s.logger(),
+	}
+}
+
+// metadata 返回注册时公布的元数据
+func (s *server) metadata() registry.Metadata {
+	md := s.config.metadata
+	if md.Weight <= 0 {
+		md.Weight = 1
+	}
+	if md.Version == "" {
+		md.Version = Version
+	}
+	if md.Features == nil {
+		md.Features = features
+	}
+	return md
+}
+
+// newClient 创建访问 peerAddr 的 client，使用 server 的配置并与其他 client 共用连接
+func (s *server) newClient(peerAddr string) *client {
+	name := fmt.Sprintf("%s/%s", s.config.prefix, peerAddr) // peerAddr -> gocache/peerAddr
+	if s.config.directDial {
+		name = peerAddr
+	}
+	return &client{
+		name:        name,
+		registry:    s.config.registry,
+		direct:      s.config.directDial,
+		pool:        s.pool,
+		dialOptions: s.config.dialOptions,
+		timeout:     s.config.rpcTimeout,
+		log:         s.log,
+	}
+}
+
+// SetLogger 设置 server 及其访问远端节点的 client 输出日志使用的 Logger
+// 需要在 SetPeers 之前调用
+func (s *server) SetLogger(l Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log = l
+}
+
+// logger 返回 server 使用的 Logger
+func (s *server) logger() Logger {
+	return loggerOrDefault(s.log)
 }
 
 // Get 实现 GoCache service 的 Get 接口
@@ -67,7 +358,7 @@ func (s *server) Get(ctx context.Context, req *pb.Request) (*pb.Response, error)
 	group, key := req.GetGroup(), req.GetKey()
 	resp := &pb.Response{}
 
-	log.Printf("[gocache_svr %s] Received RPC Request - Group: %s, Key: %s", s.addr, group, key)
+	s.logger().Debug("[gocache_svr] received Get request", "addr", s.addr, "group", group, "key", key)
 	if key == "" {
 		return resp, fmt.Errorf("key is required")
 	}
@@ -78,23 +369,107 @@ func (s *server) Get(ctx context.Context, req *pb.Request) (*pb.Response, error)
 		return resp, fmt.Errorf("group %s not found", group)
 	}
 
-	// 尝试从缓存获取数据
-	value, err := g.Get(key)
+	// 尝试从缓存获取数据，ctx 携带了调用方的 deadline
+	value, err := g.GetContext(withRedirected(ctx), key)
 	if err == nil {
-		resp.Value = value.ByteSlice()
+		resp.Value, resp.Expire = value.ByteSlice(), toUnixNano(value.Expire())
 		return resp, nil
 	}
+	if ctx.Err() != nil {
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	// key 不存在时返回 NotFound 状态码，请求方据此还原出 ErrNotFound
+	if errors.Is(err, ErrNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
 
 	// 数据不在缓存中，从数据库加载
-	view, err := g.getLocally(key)
+	view, err := g.getLocally(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to load data for key %s: %v", key, err)
 	}
 
-	resp.Value = view.ByteSlice()
+	resp.Value, resp.Expire = view.ByteSlice(), toUnixNano(view.Expire())
+	return resp, nil
+}
+
+// GetMulti 实现 GoCache service 的 GetMulti 接口，只在本节点查找或加载，不再转发
+func (s *server) GetMulti(ctx context.Context, req *pb.MultiRequest) (*pb.MultiResponse, error) {
+	group, keys := req.GetGroup(), req.GetKeys()
+	s.logger().Debug("[gocache_svr] received GetMulti request", "addr", s.addr, "group", group, "keys", len(keys))
+
+	g := GetGroup(group)
+	if g == nil {
+		return nil, fmt.Errorf("group %s not found", group)
+	}
+	values, errs := g.getMultiLocally(ctx, keys)
+	resp := &pb.MultiResponse{Values: make([]*pb.KeyValue, 0, len(keys))}
+	for _, key := range keys {
+		kv := &pb.KeyValue{Key: key}
+		if err, ok := errs[key]; ok {
+			kv.Error, kv.NotFound = err.Error(), errors.Is(err, ErrNotFound)
+		} else if v, ok := values[key]; ok {
+			kv.Value, kv.Expire = v.ByteSlice(), toUnixNano(v.Expire())
+		} else {
+			continue
+		}
+		resp.Values = append(resp.Values, kv)
+	}
 	return resp, nil
 }
 
+// Set 实现 GoCache service 的 Set 接口，将值写入本节点的缓存
+func (s *server) Set(ctx context.Context, req *pb.SetRequest) (*pb.Response, error) {
+	group, key := req.GetGroup(), req.GetKey()
+	s.logger().Debug("[gocache_svr] received Set request", "addr", s.addr, "group", group, "key", key)
+	if key == "" {
+		return nil, fmt.Errorf("key is required")
+	}
+	g := GetGroup(group)
+	if g == nil {
+		return nil, fmt.Errorf("group %s not found", group)
+	}
+	g.setLocally(key, req.GetValue(), time.Duration(req.GetTtl()))
+	return &pb.Response{}, nil
+}
+
+// Remove 实现 GoCache service 的 Remove 接口，删除本节点上的缓存值
+func (s *server) Remove(ctx context.Context, req *pb.Request) (*pb.Response, error) {
+	return s.drop(req)
+}
+
+// Invalidate 实现 GoCache service 的 Invalidate 接口，丢弃本节点持有的缓存值
+func (s *server) Invalidate(ctx context.Context, req *pb.Request) (*pb.Response, error) {
+	return s.drop(req)
+}
+
+// drop 删除本节点上的缓存值，不会再转发给其他节点
+func (s *server) drop(req *pb.Request) (*pb.Response, error) {
+	group, key := req.GetGroup(), req.GetKey()
+	s.logger().Debug("[gocache_svr] received Drop request", "addr", s.addr, "group", group, "key", key)
+	if key == "" {
+		return nil, fmt.Errorf("key is required")
+	}
+	g := GetGroup(group)
+	if g == nil {
+		return nil, fmt.Errorf("group %s not found", group)
+	}
+	g.removeLocally(key)
+	return &pb.Response{}, nil
+}
+
+// Stats 实现 GoCache service 的 Stats 接口，返回本节点上 Group 的统计数据
+func (s *server) Stats(ctx context.Context, req *pb.StatsRequest) (*pb.StatsResponse, error) {
+	g := GetGroup(req.GetGroup())
+	if g == nil {
+		return nil, fmt.Errorf("group %s not found", req.GetGroup())
+	}
+	return toPBStats(g.Stats()), nil
+}
+
 // Start 启动cache服务
 func (s *server) Start() error {
 	s.mu.Lock()
@@ -107,43 +482,82 @@ func (s *server) Start() error {
 	// 2. 初始化stop channal,这用于通知registry stop keep alive
 	// 3. 初始化tcp socket并开始监听
 	// 4. 注册rpc服务至grpc 这样grpc收到request可以分发给server处理
-	// 5. 将自己的服务名/Host地址注册至etcd 这样client可以通过etcd
+	// 5. 将自己的服务名/Host地址注册至registry(默认为etcd) 这样client可以通过registry
 	//    获取服务Host地址 从而进行通信。这样的好处是client只需知道服务名
-	//    以及etcd的Host即可获取对应服务IP 无需写死至client代码中
+	//    以及registry的地址即可获取对应服务IP 无需写死至client代码中
 	// ----------------------------------------------
 	s.status = true
+	s.stopped = false
 	s.stopSignal = make(chan error) // 创建一个接收停止信号的通道，这个通道用于从注册服务接收停止或错误信号
 
-	port := strings.Split(s.addr, ":")[1]
+	_, port, _ := net.SplitHostPort(s.addr)
 	lis, err := net.Listen("tcp", ":"+port) // 启动TCP服务器，监听指定端口
 	if err != nil {
+		s.status = false
+		s.mu.Unlock()
 		return fmt.Errorf("failed to listen: %v", err)
 	}
-	grpcServer := grpc.NewServer()             // 创建新的服务器实例
+	// 注册服务至registry，这样其他节点可以发现并连接到这个服务器
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.rpcTimeout)
+	if mr, ok := s.config.registry.(registry.MetadataRegistry); ok {
+		err = mr.RegisterInstance(ctx, s.config.prefix, registry.Instance{Addr: s.addr, Metadata: s.metadata()})
+	} else {
+		err = s.config.registry.Register(ctx, s.config.prefix, s.addr)
+	}
+	cancel()
+	if err != nil {
+		s.status = false
+		s.mu.Unlock()
+		lis.Close()
+		return fmt.Errorf("failed to register: %v", err)
+	}
+	grpcOpts := append([]grpc.ServerOption{grpc.ChainUnaryInterceptor(tracingServerInterceptor())}, s.config.grpcOptions...)
+	grpcServer := grpc.NewServer(grpcOpts...)  // 创建新的服务器实例
 	pb.RegisterGroupCacheServer(grpcServer, s) // 这个服务器实例与 gRPC 服务相关联，允许 gRPC 处理到来的请求。
+	s.startMetrics()
 
-	// 注册服务至etcd，异步运行服务注册逻辑，避免阻塞主线程
+	// 收到停止信号后注销服务
 	go func() {
-		// Register never return unless stop singnal received
-		err := registry.Register("gocache", s.addr, s.stopSignal) //注册服务器的地址到etcd，这样客户端可以通过 etcd 发现并连接到这个服务器。
-		if err != nil {
-			log.Fatalf(err.Error())
+		<-s.stopSignal
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.rpcTimeout)
+		defer cancel()
+		if err := s.config.registry.Deregister(ctx, s.config.prefix, s.addr); err != nil {
+			s.logger().Error("[gocache_svr] failed to deregister", "addr", s.addr, "err", err)
+		}
+		// 关闭 NewServer 创建的 etcd 客户端，再次 Start 时重新连接；通过 WithRegistry 传入的 Registry 由调用方关闭
+		if closer, ok := s.config.registry.(io.Closer); ok && s.config.ownRegistry {
+			if err := closer.Close(); err != nil {
+				s.logger().Error("[gocache_svr] failed to close registry", "addr", s.addr, "err", err)
+			}
 		}
 		// Close channel
 		close(s.stopSignal)
 		// Close tcp listen
-		err = lis.Close()
-		if err != nil {
-			log.Fatalf(err.Error())
+		if err := lis.Close(); err != nil {
+			s.logger().Error("[gocache_svr] failed to close tcp socket", "addr", s.addr, "err", err)
 		}
-		log.Printf("[%s] Revoke service and close tcp socket ok.", s.addr)
+		s.logger().Info("[gocache_svr] revoke service and close tcp socket ok", "addr", s.addr)
 	}()
 
+	if s.config.watchPeers {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		s.stopWatch, s.watchDone = cancel, done
+		go func() {
+			defer close(done)
+			s.watchPeers(ctx)
+		}()
+	}
+
 	//log.Printf("[%s] register service ok\n", s.addr)
 	s.mu.Unlock()
 
 	// 在之前创建的监听器上服务gRPC请求，这是一个阻塞调用，会持续监听直到服务器关闭
-	if err := grpcServer.Serve(lis); s.status && err != nil {
+	err = grpcServer.Serve(lis)
+	s.mu.Lock()
+	running := s.status
+	s.mu.Unlock()
+	if running && err != nil {
 		return fmt.Errorf("failed to serve: %v", err)
 	}
 	return nil
@@ -151,43 +565,199 @@ func (s *server) Start() error {
 
 // SetPeers 将各个远端主机IP配置到Server里
 // 这样Server就可以Pick他们了
-// 注意: 此操作是*覆写*操作！
+// 注意: 此操作是*覆写*操作！仍在列表中的节点保留原有的 client 与连接
 // 注意: peersIP必须满足 x.x.x.x:port的格式
 func (s *server) SetPeers(peersAddr ...string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	//初始化一个一致性哈希环
-	s.consHash = consistenthash.New(defaultReplicas, nil)
+	s.consHash = s.newRing()
 	//供的远程节点地址注册到一致性哈希环中
-	s.consHash.Register(peersAddr...)
-	s.clients = make(map[string]*client)
+	s.consHash.Add(peersAddr...)
+	clients := make(map[string]*client, len(peersAddr))
 	for _, peerAddr := range peersAddr {
 		if !validPeerAddr(peerAddr) {
 			panic(fmt.Sprintf("[peer %s] invalid address format, it should be x.x.x.x:port", peerAddr))
 		}
+		if _, ok := clients[peerAddr]; ok {
+			continue
+		}
+		if c, ok := s.clients[peerAddr]; ok {
+			clients[peerAddr] = c
+			delete(s.clients, peerAddr)
+			continue
+		}
 		//对于每一个有效的节点地址，创建并注册新的客户端实例
-		service := fmt.Sprintf("gocache/%s", peerAddr)
-		s.clients[peerAddr] = NewClient(service) // peerAddr -> gocache/peerAddr
-		//registry.Register(service,peerAddr,make(chan error, 1))
+		clients[peerAddr] = s.newClient(peerAddr)
 	}
+	s.closeClients() // 关闭已不在列表中的节点的 client
+	s.clients = clients
 }
 
 // Pick 根据一致性哈希选举出key应存放在的cache
 // return false 代表从本地获取cache
+// 开启有界负载时，所属节点负载过高则选出顺时针方向的下一个节点
 func (s *server) Pick(key string) (Fetcher, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.consHash == nil {
+		return nil, false
+	}
+	bounded, ok := s.consHash.(consistenthash.Bounded)
+	if !ok || s.config.loadFactor <= 0 {
+		return s.pickReplica(key)
+	}
+	peerAddr := bounded.GetLeast(key)
+	if peerAddr == "" || peerAddr == s.addr {
+		s.logger().Debug("[gocache_svr] pick myself", "addr", s.addr, "key", key)
+		return nil, false
+	}
+	c, ok := s.clients[peerAddr]
+	if !ok {
+		return nil, false
+	}
+	redirect := peerAddr != s.consHash.GetPeer(key)
+	s.logger().Debug("[gocache_svr] pick remote peer", "addr", s.addr, "key", key, "peer", peerAddr, "redirect", redirect)
+	return boundedPeer{c: c, s: s, addr: peerAddr, redirect: redirect}, true
+}
+
+// PickOwner 选出 key 所属的节点，不考虑负载，用于 Set 与 Remove
+func (s *server) PickOwner(key string) (Fetcher, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.consHash == nil {
+		return nil, false
+	}
+	return s.pickOwner(key)
+}
+
+// pickOwner 实现 PickOwner，调用前需持有 s.mu
+func (s *server) pickOwner(key string) (Fetcher, bool) {
 	peerAddr := s.consHash.GetPeer(key) //节点地址
 	// Pick itself
-	if peerAddr == s.addr {
-		log.Printf("ooh! pick myself, I am %s\n", s.addr)
+	if peerAddr == "" || peerAddr == s.addr {
+		s.logger().Debug("[gocache_svr] pick myself", "addr", s.addr, "key", key)
 		return nil, false
 	}
-	log.Printf("[cache %s] pick remote peer: %s\n", s.addr, peerAddr)
+	s.logger().Debug("[gocache_svr] pick remote peer", "addr", s.addr, "key", key, "peer", peerAddr)
 	return s.clients[peerAddr], true
+}
+
+// pickReplica 在开启副本时返回依次尝试各副本的 replicaPeer，调用前需持有 s.mu
+// 副本中排在本节点之后的节点不会被尝试：前面的节点都不可用时由本节点加载
+func (s *server) pickReplica(key string) (Fetcher, bool) {
+	addrs := s.replicas(key)
+	if addrs == nil {
+		return s.pickOwner(key)
+	}
+	for i, addr := range addrs {
+		if addr == s.addr {
+			addrs = addrs[:i]
+			break
+		}
+	}
+	switch len(addrs) {
+	case 0:
+		s.logger().Debug("[gocache_svr] pick myself", "addr", s.addr, "key", key)
+		return nil, false
+	case 1:
+		return s.pickOwner(key)
+	}
+	s.logger().Debug("[gocache_svr] pick replicas", "addr", s.addr, "key", key, "peers", addrs)
+	return replicaPeer{s: s, addrs: strings.Join(addrs, ",")}, true
+}
+
+// PickReplicas 在开启副本写入时返回 key 的远端副本，以及本节点是否也是副本
+func (s *server) PickReplicas(key string) ([]Fetcher, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
+	if s.consHash == nil || !s.config.replicaWrites {
+		return nil, false
+	}
+	addrs := s.replicas(key)
+	if addrs == nil {
+		return nil, false
+	}
+	var peers []Fetcher
+	self := false
+	for _, addr := range addrs {
+		if addr == s.addr {
+			self = true
+		} else if c, ok := s.clients[addr]; ok {
+			peers = append(peers, c)
+		}
+	}
+	return peers, self
+}
+
+// replicas 返回 key 的副本地址，没有开启副本或放置策略不支持时返回 nil，调用前需持有 s.mu
+func (s *server) replicas(key string) []string {
+	replicated, ok := s.consHash.(consistenthash.Replicated)
+	if !ok || s.config.replication <= 1 {
+		return nil
+	}
+	return replicated.GetPeers(key, s.config.replication)
+}
+
+// track 将一个发往 addr 的请求计入负载，返回的函数在请求完成时调用
+func (s *server) track(addr string) func() {
+	s.mu.Lock()
+	ring, _ := s.consHash.(consistenthash.Bounded)
+	if ring != nil {
+		ring.Inc(addr)
+	}
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		// SetPeers 可能已经替换了哈希环，此时旧环上的负载不再有意义
+		if ring != nil && consistenthash.Placement(ring) == s.consHash {
+			ring.Done(addr)
+		}
+	}
+}
+
+// PeerLoads 返回本节点发往各远端节点的进行中的请求数，只在开启有界负载时统计
+func (s *server) PeerLoads() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bounded, ok := s.consHash.(consistenthash.Bounded)
+	if !ok {
+		return map[string]int64{}
+	}
+	return bounded.Loads()
+}
+
+// newRing 按 server 的配置创建放置策略，默认为一致性哈希环，调用前需持有 s.mu
+func (s *server) newRing() consistenthash.Placement {
+	var ring consistenthash.Placement
+	if s.config.placement != nil {
+		ring = s.config.placement()
+	} else {
+		ring = consistenthash.New(s.config.replicas, s.config.hash)
+	}
+	if bounded, ok := ring.(consistenthash.Bounded); ok {
+		bounded.SetLoadFactor(s.config.loadFactor)
+	}
+	return ring
+}
+
+// Peers 返回除自身外所有远端节点的客户端
+func (s *server) Peers() []Fetcher {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	peers := make([]Fetcher, 0, len(s.clients))
+	for addr, c := range s.clients {
+		if addr != s.addr {
+			peers = append(peers, c)
+		}
+	}
+	return peers
 }
 
 // Stop 停止server运行 如果server没有运行 这将是一个no-op
@@ -199,10 +769,193 @@ func (s *server) Stop() {
 	}
 	s.stopSignal <- nil // 发送停止keepalive信号
 	s.status = false    // 设置server运行状态为stop
-	s.clients = nil     // 清空一致性哈希信息 有助于垃圾回收
+	if s.metricsServer != nil {
+		s.metricsServer.Close()
+		s.metricsServer = nil
+	}
+	s.stopped = true
+	stopWatch, watchDone := s.stopWatch, s.watchDone
+	s.stopWatch, s.watchDone = nil, nil
+	s.mu.Unlock()
+
+	// 等待监听节点变化的 goroutine 退出，它的回调需要 s.mu，因此不能持锁等待
+	if stopWatch != nil {
+		stopWatch()
+		<-watchDone
+	}
+
+	s.mu.Lock()
+	s.closeClients()
+	s.clients = nil // 清空一致性哈希信息 有助于垃圾回收
+	s.peerMeta = nil
 	s.consHash = nil
 	s.mu.Unlock()
 }
 
+// closeClients 关闭所有 client 的连接，调用前需持有 s.mu
+func (s *server) closeClients() {
+	for _, c := range s.clients {
+		c.Close()
+	}
+}
+
+// watchPeers 监听 etcd 中注册的节点直到 ctx 结束，连接出错时每秒重试一次
+// Registry 支持元数据时同时读回各节点的元数据
+func (s *server) watchPeers(ctx context.Context) {
+	for ctx.Err() == nil {
+		var err error
+		if mr, ok := s.config.registry.(registry.MetadataRegistry); ok {
+			err = mr.WatchInstances(ctx, s.config.prefix, s.updateInstances)
+		} else {
+			err = s.config.registry.Watch(ctx, s.config.prefix, s.updatePeers)
+		}
+		if err == nil {
+			continue
+		}
+		s.logger().Error("[gocache_svr] watch peers failed", "addr", s.addr, "err", err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// updateInstances 与 updatePeers 相同，同时记录各节点的元数据并交给对应的 client
+func (s *server) updateInstances(instances []registry.Instance) {
+	addrs := make([]string, 0, len(instances))
+	meta := make(map[string]registry.Metadata, len(instances))
+	for _, ins := range instances {
+		addrs = append(addrs, ins.Addr)
+		meta[ins.Addr] = ins.Metadata
+	}
+	s.syncPeers(addrs, meta)
+}
+
+// updatePeers 使哈希环与 client 与 addrs 一致：为新节点创建 client，关闭已离开节点的 client
+// 与 SetPeers 不同，未变化的节点保留原有的连接
+func (s *server) updatePeers(addrs []string) {
+	s.syncPeers(addrs, nil)
+}
+
+// syncPeers 实现 updatePeers，meta 不为 nil 时替换节点的元数据，哈希环上节点的虚拟节点数与元数据中的权重成正比
+// server 已经 Stop 时直接返回，避免 Stop 之后到达的回调重新创建哈希环与无人关闭的连接
+func (s *server) syncPeers(addrs []string, meta map[string]registry.Metadata) {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	if s.consHash == nil {
+		s.consHash = s.newRing()
+	}
+	if s.clients == nil {
+		s.clients = make(map[string]*client)
+	}
+	var added, removed []string
+	want := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		if !validPeerAddr(addr) {
+			s.logger().Warn("[gocache_svr] ignore peer with invalid address", "addr", s.addr, "peer", addr)
+			continue
+		}
+		want[addr] = true
+		if _, ok := s.clients[addr]; !ok {
+			added = append(added, addr)
+			s.clients[addr] = s.newClient(addr)
+		}
+	}
+	var closing []*client
+	for addr, c := range s.clients {
+		if !want[addr] {
+			removed = append(removed, addr)
+			closing = append(closing, c)
+			delete(s.clients, addr)
+			s.consHash.Remove(addr)
+		}
+	}
+	if meta != nil {
+		s.peerMeta = meta
+	}
+	weighted, _ := s.consHash.(consistenthash.Weighted)
+	for addr, c := range s.clients {
+		// 新节点按元数据中的权重加入哈希环，已有节点的权重变化时原地调整
+		md, ok := s.peerMeta[addr]
+		weight := md.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		if weighted != nil {
+			weighted.SetWeight(addr, weight)
+		} else {
+			s.consHash.Add(addr)
+		}
+		if ok {
+			c.setMetadata(md)
+		}
+	}
+	onChange := s.config.onPeersChange
+	s.mu.Unlock()
+
+	for _, c := range closing {
+		c.Close()
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	sort.Strings(removed)
+	s.logger().Info("[gocache_svr] peers changed", "addr", s.addr, "added", added, "removed", removed)
+	if onChange != nil {
+		onChange(added, removed)
+	}
+}
+
+// Members 返回哈希环上所有节点的地址(包括自身)，按地址排序
+func (s *server) Members() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members := make([]string, 0, len(s.clients))
+	for addr := range s.clients {
+		members = append(members, addr)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// PeerMetadata 返回从 Registry 读回的节点元数据，Registry 不支持元数据或节点不存在时 ok 为 false
+func (s *server) PeerMetadata(addr string) (md registry.Metadata, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	md, ok = s.peerMeta[addr]
+	return md, ok
+}
+
+// EnableMetrics 让 Start 同时在 addr 上启动 HTTP 服务，并在 /metrics 路径暴露 handler
+// 需要在 Start 之前调用，例如 svr.EnableMetrics(":2112", metrics.Handler())
+func (s *server) EnableMetrics(addr string, handler http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metricsAddr, s.metricsHandler = addr, handler
+}
+
+// startMetrics 启动监控指标的 HTTP 服务，调用前需持有 s.mu
+func (s *server) startMetrics() {
+	if s.metricsAddr == "" || s.metricsHandler == nil {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s.metricsHandler)
+	srv := &http.Server{Addr: s.metricsAddr, Handler: mux}
+	s.metricsServer = srv
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.logger().Error("[gocache_svr] metrics server stopped", "addr", s.addr, "err", err)
+		}
+	}()
+}
+
 // 测试Server是否实现了Picker接口
 var _ Picker = (*server)(nil)
+var _ OwnerPicker = (*server)(nil)
+var _ ReplicaPicker = (*server)(nil)
+var _ PeerLister = (*server)(nil)
@@ -1,8 +1,21 @@
 package gocache
 
 import (
+	"context"
+	"fmt"
+	"gocache/consistenthash"
+	pb "gocache/gocachepb"
+	"gocache/registry"
+	"hash/crc32"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 
@@ -96,3 +109,590 @@ func TestServerFullCycle(t *testing.T) {
 		t.Errorf("Server did not stop correctly")
 	}
 }
+
+// 测试服务器处理 Set/Remove/Invalidate 请求
+func TestServerSetRemove(t *testing.T) {
+	svr, err := NewServer("localhost:9996")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	g := NewGroup("svrWrites", 1<<10, time.Minute, GetterFunc(mockGetter))
+	ctx := context.Background()
+
+	if _, err := svr.Set(ctx, &pb.SetRequest{Group: "svrWrites", Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if v, ok := g.mainCache.get("k"); !ok || v.String() != "v" {
+		t.Fatalf("Set should populate mainCache, got %q", v.String())
+	}
+
+	if _, err := svr.Remove(ctx, &pb.Request{Group: "svrWrites", Key: "k"}); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, ok := g.mainCache.get("k"); ok {
+		t.Fatalf("Remove should drop the key")
+	}
+
+	if _, err := svr.Invalidate(ctx, &pb.Request{Group: "unknown", Key: "k"}); err == nil {
+		t.Fatalf("Invalidate on unknown group should fail")
+	}
+}
+
+// 测试服务器处理 GetMulti 请求时返回每个 key 的结果
+func TestServerGetMulti(t *testing.T) {
+	svr, err := NewServer("localhost:9996")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	NewGroup("svrMulti", 1<<10, time.Minute, GetterFunc(func(key string) ([]byte, error) {
+		if key == "bad" {
+			return nil, fmt.Errorf("%s not exist", key)
+		}
+		return []byte("v_" + key), nil
+	}))
+
+	resp, err := svr.GetMulti(context.Background(), &pb.MultiRequest{Group: "svrMulti", Keys: []string{"a", "bad", "b"}})
+	if err != nil {
+		t.Fatalf("GetMulti failed: %v", err)
+	}
+	if len(resp.Values) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(resp.Values))
+	}
+	for _, kv := range resp.Values {
+		switch kv.Key {
+		case "bad":
+			if kv.Error == "" {
+				t.Fatalf("bad should carry an error")
+			}
+		default:
+			if string(kv.Value) != "v_"+kv.Key || kv.Error != "" {
+				t.Fatalf("unexpected result for %s: %q %q", kv.Key, kv.Value, kv.Error)
+			}
+		}
+	}
+}
+
+// 测试服务器在响应中带上缓存值的过期时间
+func TestServerGetExpire(t *testing.T) {
+	svr, err := NewServer("localhost:9996")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	g := NewGroup("svrExpire", 1<<10, time.Minute, ttlLoader{})
+	ctx := context.Background()
+
+	resp, err := svr.Get(ctx, &pb.Request{Group: "svrExpire", Key: "short"})
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	v, _ := g.mainCache.get("short")
+	if resp.Expire == 0 || resp.Expire != v.Expire().UnixNano() {
+		t.Fatalf("response should carry the stored expiry %v, got %d", v.Expire(), resp.Expire)
+	}
+
+	multi, err := svr.GetMulti(ctx, &pb.MultiRequest{Group: "svrExpire", Keys: []string{"short"}})
+	if err != nil || len(multi.Values) != 1 || multi.Values[0].Expire != resp.Expire {
+		t.Fatalf("GetMulti should carry the same expiry, got %v %v", multi, err)
+	}
+	if !fromUnixNano(resp.Expire).Equal(v.Expire()) || !fromUnixNano(0).IsZero() {
+		t.Fatalf("fromUnixNano should restore the expiry")
+	}
+}
+
+// 测试不存在的 key 以 NotFound 状态码返回给请求方
+func TestServerGetNotFound(t *testing.T) {
+	svr, err := NewServer("localhost:9996")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	NewGroup("svrNotFound", 1<<10, time.Minute, &scoreLoader{db: map[string]string{"Tom": "630"}})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := svr.Get(ctx, &pb.Request{Group: "svrNotFound", Key: "Sam"}); status.Code(err) != codes.NotFound {
+			t.Fatalf("missing key should yield codes.NotFound, got %v", err)
+		}
+	}
+	resp, err := svr.GetMulti(ctx, &pb.MultiRequest{Group: "svrNotFound", Keys: []string{"Sam", "Tom"}})
+	if err != nil || len(resp.Values) != 2 {
+		t.Fatalf("GetMulti failed: %v %v", resp, err)
+	}
+	if !resp.Values[0].NotFound || resp.Values[1].NotFound {
+		t.Fatalf("only Sam should be marked not found: %v", resp.Values)
+	}
+}
+
+// 测试 ServerOption 作用于 server 以及它创建的 client
+func TestServerOptions(t *testing.T) {
+	hashed := 0
+	svr, err := NewServer("localhost:9996",
+		WithHash(func(data []byte) uint32 { hashed++; return crc32.ChecksumIEEE(data) }),
+		WithEtcdEndpoints("10.0.0.1:2379"),
+		WithEtcdAuth("root", "secret"),
+		WithServicePrefix("cluster-a"),
+		WithReplicas(3),
+		WithRPCTimeout(time.Second),
+		WithLeaseTTL(1500*time.Millisecond),
+		WithServerLogger(NopLogger),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	svr.SetPeers("localhost:9996", "localhost:9995")
+	if hashed != 2*3 {
+		t.Fatalf("ring should hash 3 replicas per peer with the given hash, got %d", hashed)
+	}
+	c := svr.clients["localhost:9995"]
+	if c.name != "cluster-a/localhost:9995" || c.rpcTimeout() != time.Second || c.logger() != NopLogger {
+		t.Fatalf("client should inherit the server config, got %s %v", c.name, c.rpcTimeout())
+	}
+	if c.registry != svr.config.registry || c.pool != svr.pool {
+		t.Fatalf("client should share the server's registry and connections")
+	}
+	if cfg := svr.registryConfig(); cfg.LeaseTTL != 2 || cfg.Etcd.Password != "secret" {
+		t.Fatalf("lease ttl should round up to 2s, got %d", cfg.LeaseTTL)
+	}
+	if !svr.config.ownRegistry {
+		t.Fatalf("etcd registry created by NewServer should be closed on Stop")
+	}
+	if own, _ := NewServer("localhost:9996", WithRegistry(registry.NewMemoryRegistry())); own.config.ownRegistry {
+		t.Fatalf("registry passed with WithRegistry belongs to the caller")
+	}
+
+	if _, err := NewServer("localhost:9996", WithReplicas(0)); err == nil {
+		t.Fatalf("zero replicas should be rejected")
+	}
+	def, _ := NewServer("localhost:9996")
+	if def.config.prefix != "gocache" || def.config.replicas != defaultReplicas || NewClient("x").rpcTimeout() != defaultRPCTimeout {
+		t.Fatalf("unexpected defaults %+v", def.config)
+	}
+}
+
+// 测试 etcd 中的节点变化同步到哈希环与 client
+func TestServerUpdatePeers(t *testing.T) {
+	var changes [][2][]string
+	svr, err := NewServer("localhost:9996", WithPeerWatch(func(added, removed []string) {
+		changes = append(changes, [2][]string{added, removed})
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if _, ok := svr.Pick("key"); ok {
+		t.Fatalf("server without peers should pick itself")
+	}
+
+	svr.updatePeers([]string{"localhost:9995", "localhost:9996"})
+	kept := svr.clients["localhost:9996"]
+	svr.updatePeers([]string{"localhost:9994", "localhost:9996", "bad addr"})
+	svr.updatePeers([]string{"localhost:9994", "localhost:9996"})
+
+	if got := svr.Members(); len(got) != 2 || got[0] != "localhost:9994" || got[1] != "localhost:9996" {
+		t.Fatalf("unexpected members %v", got)
+	}
+	if svr.clients["localhost:9996"] != kept {
+		t.Fatalf("unchanged peer should keep its client")
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 membership changes, got %v", changes)
+	}
+	if added, removed := changes[1][0], changes[1][1]; len(added) != 1 || added[0] != "localhost:9994" || len(removed) != 1 || removed[0] != "localhost:9995" {
+		t.Fatalf("unexpected change %v", changes[1])
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if peer, ok := svr.Pick(key); ok && peer.(*client).name != "gocache/localhost:9994" {
+			t.Fatalf("%s picked a removed peer %s", key, peer.(*client).name)
+		}
+	}
+}
+
+func TestValidPeerAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"localhost:9999":  true,
+		"10.0.0.1:9999":   true,
+		"[fd00::6]:9997":  true,
+		"fd00::6:9997":    false,
+		"bad addr":        false,
+		"node1.test:9999": false,
+	} {
+		if got := validPeerAddr(addr); got != want {
+			t.Errorf("validPeerAddr(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+// 测试不依赖 etcd，通过进程内的 Registry 完成注册、发现与远端获取
+func TestServerWithMemoryRegistry(t *testing.T) {
+	reg := registry.NewMemoryRegistry()
+	ctx := context.Background()
+	NewGroup("memRegistry", 1<<10, time.Minute, GetterFunc(mockGetter))
+
+	owner, err := NewServer("localhost:9990", WithRegistry(reg), WithServerLogger(NopLogger))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	caller, err := NewServer("localhost:9989", WithRegistry(reg), WithPeerWatch(nil), WithServerLogger(NopLogger))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	for _, svr := range []*server{owner, caller} {
+		go func(svr *server) {
+			if err := svr.Start(); err != nil {
+				t.Errorf("Failed to start server: %v", err)
+			}
+		}(svr)
+	}
+	waitFor(t, func() bool { return len(caller.Members()) == 2 })
+
+	caller.mu.Lock()
+	c := caller.clients["localhost:9990"]
+	caller.mu.Unlock()
+	fctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	v, err := c.FetchContext(fctx, "memRegistry", "Tom")
+	if err != nil || v.String() != "data for Tom" {
+		t.Fatalf("fetch through the memory registry failed: %q %v", v.String(), err)
+	}
+
+	owner.Stop()
+	waitFor(t, func() bool { return len(caller.Members()) == 1 })
+	if addrs, _ := reg.Resolve(ctx, "gocache"); len(addrs) != 1 || addrs[0] != "localhost:9989" {
+		t.Fatalf("stopped server should be deregistered, got %v", addrs)
+	}
+	caller.Stop()
+
+	// Stop 之后到达的节点变化不会重建哈希环与连接
+	caller.updatePeers([]string{"localhost:9990", "localhost:9989"})
+	if members := caller.Members(); len(members) != 0 || caller.pool.len() != 0 {
+		t.Fatalf("peer update after Stop should be ignored, got members %v", members)
+	}
+}
+
+// 测试直连模式下不依赖 etcd 访问 SetPeers 设置的节点，且同一节点只有一个连接
+func TestServerDirectDial(t *testing.T) {
+	NewGroup("directDial", 1<<10, time.Minute, GetterFunc(mockGetter))
+	owner, err := NewServer("localhost:9988", WithRegistry(registry.NewStaticRegistry("gocache")), WithServerLogger(NopLogger))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	go func() {
+		if err := owner.Start(); err != nil {
+			t.Errorf("Failed to start server: %v", err)
+		}
+	}()
+	defer owner.Stop()
+
+	caller, err := NewServer("localhost:9987", WithDirectDial(), WithRegistry(registry.NewStaticRegistry("gocache")), WithServerLogger(NopLogger))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	caller.SetPeers("localhost:9988", "localhost:9987")
+	c := caller.clients["localhost:9988"]
+	if c.name != "localhost:9988" || !c.direct {
+		t.Fatalf("client should dial the peer address directly, got %s", c.name)
+	}
+	waitFor(t, func() bool {
+		v, err := c.FetchContext(context.Background(), "directDial", "Tom")
+		return err == nil && v.String() == "data for Tom"
+	})
+
+	// 重新设置节点时保留仍在列表中的 client，连接不会重建
+	caller.SetPeers("localhost:9988", "localhost:9987", "localhost:9986")
+	if caller.clients["localhost:9988"] != c || caller.pool.len() != 1 {
+		t.Fatalf("unchanged peer should keep its client and connection")
+	}
+	other := NewDirectClient("localhost:9988")
+	other.pool = caller.pool
+	if _, err := other.FetchContext(context.Background(), "directDial", "Jack"); err != nil || caller.pool.len() != 1 {
+		t.Fatalf("clients of the same peer should share one connection: %v", err)
+	}
+	other.Close()
+	if caller.pool.len() != 1 {
+		t.Fatalf("connection should stay open while another client uses it")
+	}
+	caller.SetPeers("localhost:9987")
+	if caller.pool.len() != 0 {
+		t.Fatalf("connection should be closed once no client uses it")
+	}
+}
+
+// 测试节点频繁变化时并发访问远端节点，被关闭的 client 不会使用 nil 连接，需配合 -race 运行
+func TestServerPeerChurn(t *testing.T) {
+	NewGroup("peerChurn", 1<<10, time.Minute, GetterFunc(mockGetter))
+	owner, err := NewServer("localhost:9980", WithRegistry(registry.NewStaticRegistry("gocache")), WithServerLogger(NopLogger))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	go func() {
+		if err := owner.Start(); err != nil {
+			t.Errorf("Failed to start server: %v", err)
+		}
+	}()
+	defer owner.Stop()
+
+	caller, err := NewServer("localhost:9979", WithDirectDial(), WithRegistry(registry.NewStaticRegistry("gocache")), WithServerLogger(NopLogger))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	caller.updatePeers([]string{"localhost:9980", "localhost:9979"})
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				for _, peer := range caller.Peers() {
+					ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+					peer.(ContextFetcher).FetchContext(ctx, "peerChurn", "Tom")
+					cancel()
+				}
+			}
+		}()
+	}
+	for i := 0; i < 200; i++ {
+		caller.updatePeers([]string{"localhost:9979"})
+		caller.updatePeers([]string{"localhost:9980", "localhost:9979"})
+	}
+	close(done)
+	wg.Wait()
+
+	caller.SetPeers()
+	if caller.pool.len() != 0 {
+		t.Fatalf("all connections should be released after churn, %d left", caller.pool.len())
+	}
+}
+
+// 测试注册时公布元数据，并在监听节点时读回，不向缺少功能的旧节点发送请求
+func TestServerMetadata(t *testing.T) {
+	reg := registry.NewMemoryRegistry()
+	ctx := context.Background()
+	svr, err := NewServer("localhost:9985", WithRegistry(reg), WithNodeMetadata(registry.Metadata{Weight: 4, Zone: "zone-a"}),
+		WithPeerWatch(nil), WithServerLogger(NopLogger))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	go func() {
+		if err := svr.Start(); err != nil {
+			t.Errorf("Failed to start server: %v", err)
+		}
+	}()
+	defer svr.Stop()
+
+	// 只支持 GetMulti 的旧版本节点
+	reg.RegisterInstance(ctx, "gocache", registry.Instance{Addr: "localhost:9984", Metadata: registry.Metadata{Version: "v0.1.0", Features: []string{FeatureGetMulti}}})
+	waitFor(t, func() bool {
+		_, ok := svr.PeerMetadata("localhost:9984")
+		return ok && len(svr.Members()) == 2
+	})
+	md, _ := svr.PeerMetadata("localhost:9985")
+	want := registry.Metadata{Weight: 4, Zone: "zone-a", Version: Version, Features: features}
+	if !reflect.DeepEqual(md, want) {
+		t.Fatalf("expected own metadata %+v, got %+v", want, md)
+	}
+
+	svr.mu.Lock()
+	old := svr.clients["localhost:9984"]
+	svr.mu.Unlock()
+	if !old.supports(FeatureGetMulti) || old.supports(FeatureUpdate) {
+		t.Fatalf("client should follow the features of the peer")
+	}
+	if err := old.Invalidate("scores", "Tom"); err == nil || !strings.Contains(err.Error(), "does not support") {
+		t.Fatalf("invalidate should not be sent to a peer without updates, got %v", err)
+	}
+	if _, err := old.Stats(ctx, "scores"); err == nil {
+		t.Fatalf("stats should not be sent to a peer without stats")
+	}
+	if NewDirectClient("localhost:9984").supports(FeatureStats) != true {
+		t.Fatalf("client without metadata should assume every feature")
+	}
+}
+
+// 测试按元数据中的权重构建哈希环，权重变化时原地调整
+func TestServerWeightedPeers(t *testing.T) {
+	svr, err := NewServer("localhost:9996", WithReplicas(20), WithServerLogger(NopLogger))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	svr.updateInstances([]registry.Instance{
+		{Addr: "localhost:9996", Metadata: registry.Metadata{Weight: 3}},
+		{Addr: "localhost:9995"},
+	})
+	ring := svr.consHash.(*consistenthash.Consistency)
+	if ring.Weight("localhost:9996") != 3 || ring.Weight("localhost:9995") != 1 {
+		t.Fatalf("ring should follow the weights in the metadata")
+	}
+	kept := svr.clients["localhost:9995"]
+	svr.updateInstances([]registry.Instance{
+		{Addr: "localhost:9996", Metadata: registry.Metadata{Weight: 1}},
+		{Addr: "localhost:9995", Metadata: registry.Metadata{Weight: 2}},
+	})
+	if ring.Weight("localhost:9996") != 1 || ring.Weight("localhost:9995") != 2 || svr.clients["localhost:9995"] != kept {
+		t.Fatalf("weight change should update the ring in place")
+	}
+	if f := ring.Fractions(); f["localhost:9995"] <= f["localhost:9996"] {
+		t.Fatalf("heavier peer should own more of the ring, got %v", f)
+	}
+}
+
+// 测试有界负载模式下热点 key 在所属节点负载过高时转发到其他节点
+func TestServerBoundedLoad(t *testing.T) {
+	svr, err := NewServer("localhost:9996", WithBoundedLoad(1.25), WithServerLogger(NopLogger))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	svr.SetPeers("localhost:9996", "localhost:9995", "localhost:9994", "localhost:9993")
+	key := ""
+	for i := 0; key == ""; i++ {
+		if k := fmt.Sprintf("hot%d", i); svr.consHash.GetPeer(k) != "localhost:9996" {
+			key = k
+		}
+	}
+	owner := svr.consHash.GetPeer(key)
+
+	peer, ok := svr.Pick(key)
+	if p, isBounded := peer.(boundedPeer); !ok || !isBounded || p.addr != owner || p.redirect {
+		t.Fatalf("idle owner %s should be picked, got %v", owner, peer)
+	}
+	var done []func()
+	for i := 0; i < 4; i++ {
+		done = append(done, svr.track(owner))
+	}
+	if svr.PeerLoads()[owner] != 4 {
+		t.Fatalf("in-flight requests should be counted, got %v", svr.PeerLoads())
+	}
+	peer, ok = svr.Pick(key)
+	if ok {
+		if p := peer.(boundedPeer); p.addr == owner || !p.redirect {
+			t.Fatalf("overloaded owner %s should be skipped, got %s", owner, p.addr)
+		}
+	}
+	if c, _ := svr.PickOwner(key); c.(*client).name != "gocache/"+owner {
+		t.Fatalf("writes should still go to the owner")
+	}
+
+	for _, d := range done {
+		d()
+	}
+	if peer, ok = svr.Pick(key); !ok || peer.(boundedPeer).addr != owner || svr.PeerLoads()[owner] != 0 {
+		t.Fatalf("key should go back to its owner once requests complete")
+	}
+
+	// 转发来的请求只在本地处理
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(redirectHeader, "1"))
+	if !redirected(withRedirected(ctx)) || redirected(withRedirected(context.Background())) {
+		t.Fatalf("redirected requests should be marked")
+	}
+}
+
+// 测试使用其他放置策略：节点在 rendezvous 哈希中增删，Pick 选出的节点与策略一致，有界负载不生效
+func TestServerPlacement(t *testing.T) {
+	svr, err := NewServer("localhost:9996", WithServerLogger(NopLogger), WithBoundedLoad(1.25), WithPlacement(func() consistenthash.Placement {
+		return consistenthash.NewRendezvous()
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	svr.updatePeers([]string{"localhost:9996", "localhost:9995", "localhost:9994"})
+	if _, ok := svr.consHash.(*consistenthash.Rendezvous); !ok {
+		t.Fatalf("server should use the configured placement, got %T", svr.consHash)
+	}
+	if peers := svr.consHash.Peers(); len(peers) != 3 {
+		t.Fatalf("peers should be added to the placement, got %v", peers)
+	}
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owner := svr.consHash.GetPeer(key)
+		peer, ok := svr.Pick(key)
+		if ok != (owner != "localhost:9996") || (ok && peer.(*client).name != "gocache/"+owner) {
+			t.Fatalf("pick of %s should follow the placement, owner %s, got %v", key, owner, peer)
+		}
+	}
+	svr.updatePeers([]string{"localhost:9996", "localhost:9995"})
+	if peers := svr.consHash.Peers(); len(peers) != 2 || len(svr.PeerLoads()) != 0 {
+		t.Fatalf("removed peer should leave the placement, got %v", peers)
+	}
+	svr.SetPeers("localhost:9996", "localhost:9993")
+	if peers := svr.consHash.Peers(); len(peers) != 2 || peers[0] != "localhost:9993" {
+		t.Fatalf("SetPeers should rebuild the placement, got %v", peers)
+	}
+}
+
+// 测试开启副本后所属节点不可用时从下一个副本获取，副本中排在本节点之后的节点不会被尝试
+func TestServerReplication(t *testing.T) {
+	NewGroup("replicated", 1<<10, time.Minute, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("value of " + key), nil
+	}))
+	live, err := NewServer("localhost:9983", WithRegistry(registry.NewStaticRegistry("gocache")), WithServerLogger(NopLogger))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	go func() {
+		if err := live.Start(); err != nil {
+			t.Errorf("Failed to start server: %v", err)
+		}
+	}()
+	defer live.Stop()
+
+	// localhost:9981 没有启动，模拟不可用的所属节点
+	svr, err := NewServer("localhost:9982", WithDirectDial(), WithReplication(2), WithReplicatedWrites(),
+		WithRPCTimeout(time.Second), WithServerLogger(NopLogger))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	svr.SetPeers("localhost:9981", "localhost:9982", "localhost:9983")
+	ring := svr.consHash.(*consistenthash.Consistency)
+	keyFor := func(replicas ...string) string {
+		for i := 0; ; i++ {
+			if k := fmt.Sprintf("key%d", i); reflect.DeepEqual(ring.GetPeers(k, 2), replicas) {
+				return k
+			}
+		}
+	}
+
+	key := keyFor("localhost:9981", "localhost:9983")
+	peer, ok := svr.Pick(key)
+	if _, isReplica := peer.(replicaPeer); !ok || !isReplica {
+		t.Fatalf("key with two remote replicas should pick both, got %v", peer)
+	}
+	waitFor(t, func() bool {
+		v, err := peer.(replicaPeer).FetchContext(context.Background(), "replicated", key)
+		return err == nil && v.String() == "value of "+key
+	})
+	if _, err := peer.(replicaPeer).FetchContext(context.Background(), "unknown", key); err == nil {
+		t.Fatalf("error of the last replica should be returned")
+	}
+
+	if peer, ok := svr.Pick(keyFor("localhost:9981", "localhost:9982")); !ok || peer.(*client).name != "localhost:9981" {
+		t.Fatalf("replicas after this node should not be tried, got %v", peer)
+	}
+	if _, ok := svr.Pick(keyFor("localhost:9982", "localhost:9981")); ok {
+		t.Fatalf("key owned by this node should be loaded locally")
+	}
+
+	peers, self := svr.PickReplicas(keyFor("localhost:9983", "localhost:9982"))
+	if len(peers) != 1 || peers[0].(*client).name != "localhost:9983" || !self {
+		t.Fatalf("writes should go to every replica, got %v %v", peers, self)
+	}
+	svr.config.replicaWrites = false
+	if peers, self := svr.PickReplicas(key); peers != nil || self {
+		t.Fatalf("writes should not be replicated unless enabled")
+	}
+
+	// 所属节点刚被移除时，第一个可用的副本仍然带上 redirectHeader
+	svr.SetPeers("localhost:9982", "localhost:9983")
+	replicas := peer.(replicaPeer).clients()
+	if len(replicas) != 1 || replicas[0].client.name != "localhost:9983" || replicas[0].pos != 1 {
+		t.Fatalf("surviving replica should keep its position, got %+v", replicas)
+	}
+	md, _ := metadata.FromOutgoingContext(peer.(replicaPeer).outgoing(context.Background(), replicas[0].pos))
+	if len(md.Get(redirectHeader)) == 0 {
+		t.Fatalf("request to a replica that is not the owner should be marked as redirected")
+	}
+}
@@ -136,7 +136,7 @@ func (c *LFU) Peek(key interface{}) (value interface{}, expirationTime int64, ok
 		// 判断是否已经超时
 		if checkExpirationTime(ent.Value.(*entry).expirationTime) {
 			c.removeElement(ent)
-			return nil, 0, ok
+			return nil, 0, false
 		}
 		return ent.Value.(*entry).value, ent.Value.(*entry).expirationTime, true
 	}
@@ -1,46 +1,300 @@
 package singleflight
 
-import "sync"
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 //call 代表正在进行中，或已经结束的请求。
 type call struct {
-	wg	sync.WaitGroup   //使用 sync.WaitGroup 锁避免重入。
-	val	interface{}
-	err	error
+	done    chan struct{} //fn 执行结束后关闭，等待者据此返回
+	val     interface{}
+	err     error
+	waiters int            //仍在等待结果的调用者数量
+	ctx     *flightContext //fn 收到的 ctx，所有等待者都放弃后被取消
+}
+
+// PanicError 是 fn panic 时等待者收到的错误，fn 在单独的 goroutine 中执行，panic 不会让进程崩溃
+type PanicError struct {
+	Value interface{} // recover 得到的值
+	Stack []byte      // panic 时 fn 所在 goroutine 的调用栈
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("singleflight: fn panicked: %v\n%s", e.Value, e.Stack)
 }
 
 //Group 是 singleflight 的主数据结构，管理不同 key 的请求(call)。
 type Flight struct {
 	mu	sync.Mutex   	//protexts m 为了保护m不被并发读写而加上的锁
 	m	map[string]*call
+	dups	int64		//加入进行中请求的调用次数，原子操作维护
+}
+
+// Dups 返回因同一个 key 已有进行中的请求而没有再调用 fn 的次数
+func (g *Flight) Dups() int64 {
+	return atomic.LoadInt64(&g.dups)
 }
 
 
 //Do 方法，接收 2 个参数，第一个参数是 key，第二个参数是一个函数 fn。Do 的作用就是，针对相同的 key，
 // 无论 Do 被调用多少次，函数 fn 都只会被调用一次，等待 fn 调用结束了，返回返回值或错误。
 func (g *Flight) Fly(key string, fn func() (interface{}, error)) (interface{}, error) { 
+	return g.FlyContext(context.Background(), key, func(context.Context) (interface{}, error) {
+		return fn()
+	})
+}
+
+// FlyContext 与 Fly 相同，但 fn 会收到一个 ctx：
+// 它带有发起本次请求的调用者的 value，deadline 为所有加入的调用者中最晚的 deadline(有调用者没有 deadline 时没有 deadline)，
+// 并在所有等待者都因自身 ctx 结束而放弃后被取消。
+// 每个调用者在自己的 ctx 结束时立即返回 ctx.Err()，不必等待 fn 执行完毕。
+func (g *Flight) FlyContext(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
 	g.mu.Lock()		    // 修改m需要加锁
 	if g.m == nil {
 		g.m = make(map[string]*call)
 	}
-	if c, ok := g.m[key]; ok {
-		g.mu.Unlock()
-		c.wg.Wait()   // 如果请求正在进行中，则等待
-		return c.val, c.err  //执行完成直接返回
+	c, ok := g.m[key]
+	if ok && c.ctx.Err() != nil {
+		// 进行中的请求已超时，加入它只会得到同样的错误
+		ok = false
 	}
-	c := new(call)
-	c.wg.Add(1)		 // 发起请求前加锁
-	g.m[key] = c	 // 添加到 g.m，表明 key 已经有对应的请求在处理
+	if !ok {
+		// 没有进行中的请求，发起一个新的请求
+		c = &call{done: make(chan struct{}), ctx: newFlightContext(ctx)}
+		g.m[key] = c	 // 添加到 g.m，表明 key 已经有对应的请求在处理
+		go g.run(key, c, fn)
+	} else {
+		atomic.AddInt64(&g.dups, 1)
+		c.ctx.extend(ctx)
+	}
+	c.waiters++
 	g.mu.Unlock()	 // 修改m结束，解锁
 
-	c.val, c.err = fn()  // 调用 fn，发起请求
-	c.wg.Done()			 // 请求结束
+	return g.wait(ctx, key, c)
+}
+
+// FlyBatch 与 FlyContext 相同，但一次处理多个 key：已有进行中请求的 key 加入该请求，
+// 其余的 key 由一次 fn 调用共同加载，期间其他调用者对这些 key 的 FlyContext 与 FlyBatch 会等待本次结果。
+// fn 需要为每个 key 返回值或错误，缺失的 key 得到一个错误；返回每个 key 的值与错误
+func (g *Flight) FlyBatch(ctx context.Context, keys []string, fn func(ctx context.Context, keys []string) (map[string]interface{}, map[string]error)) (map[string]interface{}, map[string]error) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	calls := make(map[string]*call, len(keys))
+	var owned []string
+	fctx := newFlightContext(ctx)
+	for _, key := range keys {
+		if _, ok := calls[key]; ok {
+			continue
+		}
+		c, ok := g.m[key]
+		if ok && c.ctx.Err() != nil {
+			ok = false
+		}
+		if ok {
+			atomic.AddInt64(&g.dups, 1)
+			c.ctx.extend(ctx)
+		} else {
+			// 本次发起的请求共用 fctx，所有 key 都被放弃后才取消
+			c = &call{done: make(chan struct{}), ctx: fctx}
+			g.m[key] = c
+			owned = append(owned, key)
+		}
+		c.waiters++
+		calls[key] = c
+	}
+	fctx.refs = len(owned)
+	g.mu.Unlock()
 
-	g.mu.Lock()			// 修该m,mu加锁
-	delete(g.m, key)	//更新 g.m
-	g.mu.Unlock()		// 解锁
+	if len(owned) > 0 {
+		go g.runBatch(owned, calls, fn)
+	} else {
+		fctx.cancel()
+	}
+	values, errs := make(map[string]interface{}, len(calls)), make(map[string]error)
+	for key, c := range calls {
+		v, err := g.wait(ctx, key, c)
+		if err != nil {
+			errs[key] = err
+			continue
+		}
+		values[key] = v
+	}
+	return values, errs
+}
+
+// wait 等待 c 结束，ctx 先结束时放弃等待；最后一个等待者放弃时取消 fn，并让后来者发起新的请求
+func (g *Flight) wait(ctx context.Context, key string, c *call) (interface{}, error) {
+	select {
+	case <-c.done:
+		return c.val, c.err  //执行完成直接返回
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			// 没有人再关心结果，取消 fn，并让后来者发起新的请求
+			c.ctx.release()
+			g.forget(key, c)
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// run 执行 fn 并唤醒所有等待者，fn panic 时等待者收到 *PanicError
+func (g *Flight) run(key string, c *call, fn func(ctx context.Context) (interface{}, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.val, c.err = nil, &PanicError{Value: r, Stack: debug.Stack()}
+		}
+		g.mu.Lock()			// 修该m,mu加锁
+		g.forget(key, c)	//更新 g.m
+		g.mu.Unlock()		// 解锁
+		c.ctx.cancel()
+		close(c.done)		 // 请求结束
+	}()
+	c.val, c.err = fn(c.ctx)  // 调用 fn，发起请求
+}
 
-	return c.val, c.err
+// runBatch 以 keys 调用一次 fn，把结果分发给每个 key 的等待者
+func (g *Flight) runBatch(keys []string, calls map[string]*call, fn func(ctx context.Context, keys []string) (map[string]interface{}, map[string]error)) {
+	ctx := calls[keys[0]].ctx
+	var values map[string]interface{}
+	var errs map[string]error
+	defer func() {
+		var panicErr error
+		if r := recover(); r != nil {
+			panicErr = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+		g.mu.Lock()
+		for _, key := range keys {
+			c := calls[key]
+			v, ok := values[key]
+			switch {
+			case panicErr != nil:
+				c.err = panicErr
+			case errs[key] != nil:
+				c.err = errs[key]
+			case ok:
+				c.val = v
+			default:
+				c.err = fmt.Errorf("singleflight: no result for key %s", key)
+			}
+			g.forget(key, c)
+		}
+		g.mu.Unlock()
+		ctx.cancel()
+		for _, key := range keys {
+			close(calls[key].done)
+		}
+	}()
+	values, errs = fn(ctx, keys)
+}
+
+// flightContext 是 fn 收到的 ctx，保留第一个调用者的 value，
+// deadline 随加入的调用者延长到其中最晚的一个，避免后来的调用者因为第一个调用者的 deadline 而失败
+type flightContext struct {
+	context.Context                    // 不随调用者取消的 ctx，保留第一个调用者的 value
+	stop            context.CancelFunc // 取消 Context
+
+	mu          sync.Mutex
+	deadline    time.Time
+	hasDeadline bool        // 为 false 时没有 deadline
+	timer       *time.Timer // deadline 到达时取消 Context
+	expired     bool        // 因 deadline 到达而被取消
+	refs        int         // 共用该 ctx 的请求数，全部被放弃后才取消
+}
+
+func newFlightContext(ctx context.Context) *flightContext {
+	base, stop := context.WithCancel(context.WithoutCancel(ctx))
+	c := &flightContext{Context: base, stop: stop, refs: 1}
+	c.deadline, c.hasDeadline = ctx.Deadline()
+	if c.hasDeadline {
+		c.timer = time.AfterFunc(time.Until(c.deadline), c.expire)
+	}
+	return c
+}
+
+func (c *flightContext) Deadline() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deadline, c.hasDeadline
+}
+
+// Err 在 deadline 到达后返回 context.DeadlineExceeded，与 context.WithDeadline 一致
+func (c *flightContext) Err() error {
+	err := c.Context.Err()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil && c.expired {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// extend 以加入的调用者的 ctx 延长 deadline，ctx 没有 deadline 时取消 deadline
+func (c *flightContext) extend(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.hasDeadline {
+		return
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		c.hasDeadline = false
+		c.timer.Stop()
+		return
+	}
+	if deadline.After(c.deadline) {
+		c.deadline = deadline
+		c.timer.Reset(time.Until(deadline))
+	}
+}
+
+// expire 在 deadline 到达时取消 Context，deadline 已被延长时什么也不做
+func (c *flightContext) expire() {
+	c.mu.Lock()
+	if !c.hasDeadline || time.Now().Before(c.deadline) {
+		c.mu.Unlock()
+		return
+	}
+	c.expired = true
+	c.mu.Unlock()
+	c.stop()
+}
+
+// release 在一个共用该 ctx 的请求被放弃时调用，所有请求都被放弃后取消 Context
+func (c *flightContext) release() {
+	c.mu.Lock()
+	c.refs--
+	last := c.refs <= 0
+	c.mu.Unlock()
+	if last {
+		c.cancel()
+	}
+}
+
+// cancel 取消 Context 并停止计时器
+func (c *flightContext) cancel() {
+	c.mu.Lock()
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.mu.Unlock()
+	c.stop()
+}
+
+// forget 在 key 仍对应 c 时将其从 g.m 中删除，调用前需持有 g.mu
+func (g *Flight) forget(key string, c *call) {
+	if g.m[key] == c {
+		delete(g.m, key)
+	}
 }
 
 /*
@@ -1,7 +1,12 @@
 package singleflight
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestDo(t *testing.T) {
@@ -13,4 +18,224 @@ func TestDo(t *testing.T) {
 	if v != "bar" || err != nil {
 		t.Errorf("Do v = %v, error = %v", v, err)
 	}
-}
\ No newline at end of file
+}
+
+func TestFlyContextDedup(t *testing.T) {
+	var g Flight
+	var calls int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := g.FlyContext(context.Background(), "key", fn); v != "bar" || err != nil {
+				t.Errorf("FlyContext v = %v, error = %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("fn should be called once, got %d", n)
+	}
+	if n := g.Dups(); n != 9 {
+		t.Errorf("9 calls should be deduplicated, got %d", n)
+	}
+}
+
+func TestFlyContextCancel(t *testing.T) {
+	var g Flight
+	canceled := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Errorf("fn should inherit the caller's deadline")
+		}
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := g.FlyContext(ctx, "key", fn); err != context.DeadlineExceeded {
+		t.Fatalf("FlyContext should return the caller's ctx error, got %v", err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatalf("fn should be canceled once every caller gave up")
+	}
+
+	// 之前的请求已被放弃，新的调用者会重新发起请求
+	v, err := g.FlyContext(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		return "bar", nil
+	})
+	if v != "bar" || err != nil {
+		t.Fatalf("FlyContext v = %v, error = %v", v, err)
+	}
+}
+
+// 后加入的调用者 deadline 更晚时，fn 的 deadline 随之延长，不会因为第一个调用者的 deadline 而失败
+func TestFlyContextExtendDeadline(t *testing.T) {
+	var g Flight
+	started := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		select {
+		case <-time.After(100 * time.Millisecond):
+			return "bar", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	short, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	first := make(chan error, 1)
+	go func() {
+		_, err := g.FlyContext(short, "key", fn)
+		first <- err
+	}()
+	<-started
+
+	long, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if v, err := g.FlyContext(long, "key", fn); v != "bar" || err != nil {
+		t.Fatalf("caller with a longer deadline should get the value, got %v %v", v, err)
+	}
+	if err := <-first; err != context.DeadlineExceeded {
+		t.Fatalf("first caller should still give up at its own deadline, got %v", err)
+	}
+}
+
+// fn 的 ctx 在最晚的 deadline 到达时结束，Err 返回 context.DeadlineExceeded
+func TestFlightContextDeadline(t *testing.T) {
+	short, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ctx := newFlightContext(short)
+	long, cancel := context.WithTimeout(context.Background(), 60*time.Millisecond)
+	defer cancel()
+	ctx.extend(long)
+	if d, ok := ctx.Deadline(); !ok || !d.Equal(mustDeadline(long)) {
+		t.Fatalf("deadline should be extended to the latest caller's")
+	}
+
+	select {
+	case <-ctx.Done():
+		t.Fatalf("ctx should outlive the first caller's deadline")
+	case <-time.After(40 * time.Millisecond):
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("ctx should end at the extended deadline")
+	}
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Fatalf("Err should be context.DeadlineExceeded, got %v", ctx.Err())
+	}
+
+	unbounded := newFlightContext(short)
+	unbounded.extend(context.Background())
+	if _, ok := unbounded.Deadline(); ok {
+		t.Fatalf("caller without a deadline should remove the deadline")
+	}
+	unbounded.cancel()
+	if unbounded.Err() != context.Canceled {
+		t.Fatalf("canceled ctx should return context.Canceled, got %v", unbounded.Err())
+	}
+}
+
+func mustDeadline(ctx context.Context) time.Time {
+	d, _ := ctx.Deadline()
+	return d
+}
+
+func TestFlyPanic(t *testing.T) {
+	var g Flight
+	_, err := g.Fly("key", func() (interface{}, error) {
+		panic("boom")
+	})
+	var perr *PanicError
+	if !errors.As(err, &perr) || perr.Value != "boom" || len(perr.Stack) == 0 {
+		t.Fatalf("panic in fn should be returned as *PanicError, got %v", err)
+	}
+	if v, err := g.Fly("key", func() (interface{}, error) { return "bar", nil }); v != "bar" || err != nil {
+		t.Fatalf("key should be released after a panic, got %v %v", v, err)
+	}
+}
+
+// FlyBatch 加入已在进行中的 key，其余 key 一次加载，期间的 FlyContext 等待批量加载的结果
+func TestFlyBatch(t *testing.T) {
+	var g Flight
+	release := make(chan struct{})
+	started := make(chan struct{})
+	single := make(chan interface{}, 1)
+	go func() {
+		v, _ := g.FlyContext(context.Background(), "a", func(ctx context.Context) (interface{}, error) {
+			close(started)
+			<-release
+			return "single a", nil
+		})
+		single <- v
+	}()
+	<-started
+
+	var batches [][]string
+	batchStarted := make(chan struct{})
+	done := make(chan struct{})
+	var values map[string]interface{}
+	var errs map[string]error
+	go func() {
+		defer close(done)
+		values, errs = g.FlyBatch(context.Background(), []string{"a", "b", "c", "b"}, func(ctx context.Context, keys []string) (map[string]interface{}, map[string]error) {
+			batches = append(batches, keys)
+			close(batchStarted)
+			<-release
+			return map[string]interface{}{"b": "batch b"}, nil
+		})
+	}()
+	<-batchStarted
+
+	// 批量加载进行中时，单个 key 的调用加入它而不是再次加载
+	joined := make(chan interface{}, 1)
+	go func() {
+		v, _ := g.FlyContext(context.Background(), "b", func(ctx context.Context) (interface{}, error) {
+			t.Errorf("b is being loaded by the batch")
+			return nil, nil
+		})
+		joined <- v
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	<-done
+
+	if len(batches) != 1 || len(batches[0]) != 2 || batches[0][0] != "b" || batches[0][1] != "c" {
+		t.Fatalf("batch should only load keys not in flight, got %v", batches)
+	}
+	if values["a"] != "single a" || values["b"] != "batch b" || errs["c"] == nil || <-single != "single a" || <-joined != "batch b" {
+		t.Fatalf("unexpected results %v %v", values, errs)
+	}
+	if g.Dups() != 2 {
+		t.Fatalf("a and the second b caller should be deduplicated, got %d", g.Dups())
+	}
+}
+
+func TestFlyBatchPanic(t *testing.T) {
+	var g Flight
+	_, errs := g.FlyBatch(context.Background(), []string{"a", "b"}, func(ctx context.Context, keys []string) (map[string]interface{}, map[string]error) {
+		panic("boom")
+	})
+	var perr *PanicError
+	if len(errs) != 2 || !errors.As(errs["a"], &perr) {
+		t.Fatalf("every key should get the panic, got %v", errs)
+	}
+}
@@ -0,0 +1,160 @@
+package gocache
+
+import (
+	"errors"
+	"sync/atomic"
+
+	pb "gocache/gocachepb"
+)
+
+// stats 模块统计 Group 的运行情况
+// 计数器都以原子操作维护，Stats() 可以在任意协程中调用
+
+// CacheKind 区分 Group 中的 mainCache 与 hotCache
+type CacheKind int
+
+const (
+	// MainCache 存放本节点负责的 key
+	MainCache CacheKind = iota + 1
+	// HotCache 存放从远端节点获取的热点 key 的副本
+	HotCache
+)
+
+// CacheStats 是某个缓存的统计数据
+type CacheStats struct {
+	Bytes       int64 // 已使用的字节数
+	Items       int64 // 条目数
+	Gets        int64 // 查找次数
+	Hits        int64 // 命中次数
+	Evictions   int64 // 因容量不足被淘汰的条目数
+	Expirations int64 // 因过期被删除的条目数
+}
+
+// Stats 是 Group 统计数据的快照
+type Stats struct {
+	Gets         int64 // Get/GetMulti 请求的 key 数
+	Hits         int64 // 命中 mainCache 或 hotCache 的次数
+	Misses       int64 // 未命中缓存的次数
+	PeerLoads    int64 // 从远端节点获取成功的次数
+	PeerErrors   int64 // 从远端节点获取失败的次数
+	LocalLoads   int64 // 调用 Getter 加载的次数
+	LoaderErrors int64 // Getter 返回错误的次数，ErrNotFound 不计入
+	Dedups       int64 // 被 singleflight 合并的加载次数
+	MainCache    CacheStats
+	HotCache     CacheStats
+}
+
+// groupStats 保存 Group 的计数器
+type groupStats struct {
+	gets, hits, misses       int64
+	peerLoads, peerErrors    int64
+	localLoads, loaderErrors int64
+}
+
+// countLookup 记录一次缓存查找，ok 为是否命中
+func (g *Group) countLookup(ok *bool) {
+	atomic.AddInt64(&g.stats.gets, 1)
+	if *ok {
+		atomic.AddInt64(&g.stats.hits, 1)
+	} else {
+		atomic.AddInt64(&g.stats.misses, 1)
+	}
+}
+
+// countPeerLoad 记录一次远端获取，远端明确告知 key 不存在也算作成功
+func (g *Group) countPeerLoad(err error) {
+	if err == nil || errors.Is(err, ErrNotFound) {
+		atomic.AddInt64(&g.stats.peerLoads, 1)
+	} else {
+		atomic.AddInt64(&g.stats.peerErrors, 1)
+	}
+}
+
+// countLocalLoad 记录一次调用 Getter 的加载，ErrNotFound 不算作错误
+func (g *Group) countLocalLoad(err error) {
+	atomic.AddInt64(&g.stats.localLoads, 1)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		atomic.AddInt64(&g.stats.loaderErrors, 1)
+	}
+}
+
+// Stats 返回 Group 当前的统计数据
+func (g *Group) Stats() Stats {
+	return Stats{
+		Gets:         atomic.LoadInt64(&g.stats.gets),
+		Hits:         atomic.LoadInt64(&g.stats.hits),
+		Misses:       atomic.LoadInt64(&g.stats.misses),
+		PeerLoads:    atomic.LoadInt64(&g.stats.peerLoads),
+		PeerErrors:   atomic.LoadInt64(&g.stats.peerErrors),
+		LocalLoads:   atomic.LoadInt64(&g.stats.localLoads),
+		LoaderErrors: atomic.LoadInt64(&g.stats.loaderErrors),
+		Dedups:       g.flight.Dups(),
+		MainCache:    g.mainCache.stats(),
+		HotCache:     g.hotCache.stats(),
+	}
+}
+
+// CacheStats 返回 mainCache 或 hotCache 的统计数据
+func (g *Group) CacheStats(which CacheKind) CacheStats {
+	switch which {
+	case MainCache:
+		return g.mainCache.stats()
+	case HotCache:
+		return g.hotCache.stats()
+	default:
+		return CacheStats{}
+	}
+}
+
+// toPBStats 与 fromPBStats 在 Stats 与 gRPC 消息之间转换
+func toPBStats(s Stats) *pb.StatsResponse {
+	return &pb.StatsResponse{
+		Gets:         s.Gets,
+		Hits:         s.Hits,
+		Misses:       s.Misses,
+		PeerLoads:    s.PeerLoads,
+		PeerErrors:   s.PeerErrors,
+		LocalLoads:   s.LocalLoads,
+		LoaderErrors: s.LoaderErrors,
+		Dedups:       s.Dedups,
+		MainCache:    toPBCacheStats(s.MainCache),
+		HotCache:     toPBCacheStats(s.HotCache),
+	}
+}
+
+func toPBCacheStats(s CacheStats) *pb.CacheStats {
+	return &pb.CacheStats{
+		Bytes:       s.Bytes,
+		Items:       s.Items,
+		Gets:        s.Gets,
+		Hits:        s.Hits,
+		Evictions:   s.Evictions,
+		Expirations: s.Expirations,
+	}
+}
+
+func fromPBStats(resp *pb.StatsResponse) Stats {
+	return Stats{
+		Gets:         resp.GetGets(),
+		Hits:         resp.GetHits(),
+		Misses:       resp.GetMisses(),
+		PeerLoads:    resp.GetPeerLoads(),
+		PeerErrors:   resp.GetPeerErrors(),
+		LocalLoads:   resp.GetLocalLoads(),
+		LoaderErrors: resp.GetLoaderErrors(),
+		Dedups:       resp.GetDedups(),
+		MainCache:    fromPBCacheStats(resp.GetMainCache()),
+		HotCache:     fromPBCacheStats(resp.GetHotCache()),
+	}
+}
+
+func fromPBCacheStats(s *pb.CacheStats) CacheStats {
+	return CacheStats{
+		Bytes:       s.GetBytes(),
+		Items:       s.GetItems(),
+		Gets:        s.GetGets(),
+		Hits:        s.GetHits(),
+		Evictions:   s.GetEvictions(),
+		Expirations: s.GetExpirations(),
+	}
+}
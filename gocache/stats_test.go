@@ -0,0 +1,104 @@
+package gocache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	pb "gocache/gocachepb"
+)
+
+// 测试 Group 各计数器
+func TestGroupStats(t *testing.T) {
+	owner := &fakePeer{values: map[string][]byte{"remoteA": []byte("a")}}
+	release := make(chan struct{})
+	g := NewGroup("statsGroup", 1<<10, time.Minute, GetterFunc(func(key string) ([]byte, error) {
+		switch key {
+		case "bad":
+			return nil, fmt.Errorf("%s not exist", key)
+		case "none":
+			return nil, ErrNotFound
+		case "slow":
+			<-release
+		}
+		return []byte(key), nil
+	}))
+	g.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner}})
+
+	g.Get("Tom")
+	g.Get("Tom")
+	g.Get("remoteA")
+	g.Get("remoteB") // owner 返回错误，回退到本地加载
+	g.Get("bad")
+	g.Get("none")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Get("slow")
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	s := g.Stats()
+	want := Stats{Gets: 9, Hits: 1, Misses: 8, PeerLoads: 1, PeerErrors: 1, LocalLoads: 5, LoaderErrors: 1, Dedups: 2}
+	if s.Gets != want.Gets || s.Hits != want.Hits || s.Misses != want.Misses ||
+		s.PeerLoads != want.PeerLoads || s.PeerErrors != want.PeerErrors ||
+		s.LocalLoads != want.LocalLoads || s.LoaderErrors != want.LoaderErrors || s.Dedups != want.Dedups {
+		t.Fatalf("unexpected stats\n got %+v\nwant %+v", s, want)
+	}
+	// Tom、remoteB、slow 与 none 的墓碑值
+	if s.MainCache.Items != 4 || s.MainCache.Bytes != int64(len("Tom")*2+len("remoteB")*2+len("slow")*2+len("none")) {
+		t.Fatalf("unexpected mainCache stats %+v", s.MainCache)
+	}
+}
+
+// 测试各淘汰算法统计淘汰与过期，但不统计主动删除
+func TestCacheStatsEvictions(t *testing.T) {
+	for _, typ := range allCacheTypes {
+		c := &cache{cacheType: typ, capacity: 20}
+		c.add("key0", ByteView{b: []byte("value0")}, 0)
+		c.add("key1", ByteView{b: []byte("value1")}, 0)
+		c.add("key2", ByteView{b: []byte("value2")}, 0) // 超出 20 字节，淘汰一个
+		c.add("key3", ByteView{b: []byte("v3")}, time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+		c.get("key3")
+		c.remove("key2")
+
+		s := c.stats()
+		if s.Evictions < 1 || s.Expirations != 1 {
+			t.Fatalf("[%s] unexpected stats %+v", typ, s)
+		}
+		if s.Gets != 1 || s.Hits != 0 {
+			t.Fatalf("[%s] unexpected gets %+v", typ, s)
+		}
+	}
+}
+
+// 测试 Stats gRPC 接口返回与 Group.Stats 一致的数据
+func TestServerStats(t *testing.T) {
+	svr, err := NewServer("localhost:9996")
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	g := NewGroup("svrStats", 1<<10, time.Minute, GetterFunc(mockGetter))
+	g.Get("Tom")
+	g.Get("Tom")
+
+	resp, err := svr.Stats(context.Background(), &pb.StatsRequest{Group: "svrStats"})
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if s := fromPBStats(resp); s != g.Stats() || s.Hits != 1 || s.MainCache.Items != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	if _, err := svr.Stats(context.Background(), &pb.StatsRequest{Group: "unknown"}); err == nil {
+		t.Fatalf("Stats on unknown group should fail")
+	}
+}
@@ -0,0 +1,138 @@
+package gocache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// tracing 模块为 Group.Get、访问远端节点和调用 Getter 创建 OpenTelemetry span
+// 节点之间通过 gRPC metadata 以 W3C Trace Context 格式传递链路信息
+
+const tracerName = "gocache"
+
+// span 属性
+const (
+	attrGroup    = attribute.Key("gocache.group")
+	attrKeyHash  = attribute.Key("gocache.key_hash") // key 可能包含敏感信息，只记录其哈希值
+	attrKeys     = attribute.Key("gocache.keys")
+	attrCacheHit = attribute.Key("gocache.cache_hit")
+	attrPeer     = attribute.Key("gocache.peer")
+)
+
+type tracerProviderHolder struct {
+	oteltrace.TracerProvider
+}
+
+var tracerProvider atomic.Value // tracerProviderHolder
+
+// SetTracerProvider 设置创建 span 使用的 TracerProvider
+// 未设置或传入 nil 时使用 otel.GetTracerProvider() 返回的全局 TracerProvider
+func SetTracerProvider(tp oteltrace.TracerProvider) {
+	tracerProvider.Store(tracerProviderHolder{tp})
+}
+
+// tracer 返回当前 TracerProvider 的 gocache Tracer
+func tracer() oteltrace.Tracer {
+	h, _ := tracerProvider.Load().(tracerProviderHolder)
+	if h.TracerProvider == nil {
+		return otel.GetTracerProvider().Tracer(tracerName)
+	}
+	return h.Tracer(tracerName)
+}
+
+// propagator 在 gRPC metadata 中读写链路信息
+var propagator propagation.TextMapPropagator = propagation.TraceContext{}
+
+// startSpan 以 group 和 key 的哈希值为属性创建 span
+func startSpan(ctx context.Context, name string, group string, key string, opts ...oteltrace.SpanStartOption) (context.Context, oteltrace.Span) {
+	ctx, span := tracer().Start(ctx, name, opts...)
+	span.SetAttributes(attrGroup.String(group), attrKeyHash.String(hashKey(key)))
+	return ctx, span
+}
+
+// endSpan 记录 err 并结束 span，key 不存在不视为错误
+func endSpan(span oteltrace.Span, err error) {
+	if err != nil && !errors.Is(err, ErrNotFound) && status.Code(err) != codes.NotFound {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
+
+// hashKey 返回 key 的 FNV-1a 哈希值
+func hashKey(key string) string {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// peerName 返回 fetcher 对应的节点名称，用作 span 属性
+func peerName(fetcher Fetcher) string {
+	if s, ok := fetcher.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", fetcher)
+}
+
+// metadataCarrier 让 propagator 读写 gRPC metadata
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// tracingClientInterceptor 将 ctx 中的链路信息写入发往远端节点的 gRPC metadata
+func tracingClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		md, ok := metadata.FromOutgoingContext(ctx)
+		if ok {
+			md = md.Copy()
+		} else {
+			md = metadata.MD{}
+		}
+		propagator.Inject(ctx, metadataCarrier(md))
+		return invoker(metadata.NewOutgoingContext(ctx, md), method, req, reply, cc, opts...)
+	}
+}
+
+// tracingServerInterceptor 从请求的 gRPC metadata 中恢复链路信息，并为每个请求创建 span
+func tracingServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			ctx = propagator.Extract(ctx, metadataCarrier(md))
+		}
+		ctx, span := tracer().Start(ctx, info.FullMethod, oteltrace.WithSpanKind(oteltrace.SpanKindServer))
+		resp, err := handler(ctx, req)
+		endSpan(span, err)
+		return resp, err
+	}
+}
@@ -0,0 +1,104 @@
+package gocache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// newSpanRecorder 将 span 记录到内存中，测试结束后恢复全局 TracerProvider
+func newSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	sr := tracetest.NewSpanRecorder()
+	SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	t.Cleanup(func() { SetTracerProvider(nil) })
+	return sr
+}
+
+// spanAttr 返回 span 上名为 key 的属性
+func spanAttr(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+// 测试 Get 为查找缓存、访问远端节点和调用 Getter 创建 span
+func TestTracingSpans(t *testing.T) {
+	sr := newSpanRecorder(t)
+	owner := &fakePeer{values: map[string][]byte{"remoteA": []byte("a")}}
+	g := NewGroup("tracing", 1<<10, time.Minute, GetterFunc(mockGetter))
+	g.RegisterPeers(&fakePicker{owner: owner, all: []*fakePeer{owner}})
+
+	g.Get("local")
+	g.Get("local")
+	g.Get("remoteA")
+
+	spans := sr.Ended()
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.Name())
+	}
+	want := []string{"gocache.Getter", "gocache.Group.Get", "gocache.Group.Get", "gocache.peer.Fetch", "gocache.Group.Get"}
+	if len(names) != len(want) {
+		t.Fatalf("expected spans %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("expected spans %v, got %v", want, names)
+		}
+	}
+
+	getter, miss, hit, fetch, remote := spans[0], spans[1], spans[2], spans[3], spans[4]
+	if getter.Parent().SpanID() != miss.SpanContext().SpanID() || fetch.Parent().SpanID() != remote.SpanContext().SpanID() {
+		t.Fatalf("loader and peer spans should be children of Group.Get")
+	}
+	if v, _ := spanAttr(miss, attrCacheHit); v.AsBool() {
+		t.Fatalf("first Get should be a miss")
+	}
+	if v, _ := spanAttr(hit, attrCacheHit); !v.AsBool() {
+		t.Fatalf("second Get should be a hit")
+	}
+	if v, _ := spanAttr(miss, attrKeyHash); v.AsString() != hashKey("local") || v.AsString() == "local" {
+		t.Fatalf("span should carry the hashed key, got %q", v.AsString())
+	}
+	if v, ok := spanAttr(fetch, attrPeer); !ok || v.AsString() == "" {
+		t.Fatalf("peer span should carry the picked peer")
+	}
+}
+
+// 测试链路信息经 gRPC metadata 从客户端传递到服务端
+func TestTracingPropagation(t *testing.T) {
+	sr := newSpanRecorder(t)
+	ctx, span := startSpan(context.Background(), "caller", "tracing", "k")
+
+	var md metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	if err := tracingClientInterceptor()(ctx, "/gocachepb.GroupCache/Get", nil, nil, nil, invoker); err != nil {
+		t.Fatalf("client interceptor failed: %v", err)
+	}
+	span.End()
+	if len(md.Get("traceparent")) == 0 {
+		t.Fatalf("outgoing metadata should carry traceparent, got %v", md)
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/gocachepb.GroupCache/Get"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	tracingServerInterceptor()(metadata.NewIncomingContext(context.Background(), md), nil, info, handler)
+
+	spans := sr.Ended()
+	server := spans[len(spans)-1]
+	if server.Name() != info.FullMethod || server.Parent().SpanID() != span.SpanContext().SpanID() {
+		t.Fatalf("server span should continue the caller's trace")
+	}
+}
@@ -2,6 +2,7 @@ package gocache
 
 import (
 	"fmt"
+	"net"
 	"runtime"
 	"strings"
 )
@@ -22,14 +23,17 @@ func trace(errorMessage string) string {
 	return str.String()
 }
 
-// 判断是否满足 x.x.x.x:port 的格式
+// 判断是否满足 x.x.x.x:port 的格式，IPv6 地址需要写成 [x:x::x]:port
 func validPeerAddr(addr string) bool {
-	token1 := strings.Split(addr, ":")
-	if len(token1) != 2 {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
 		return false
 	}
-	token2 := strings.Split(token1[0], ".")
-	if token1[0] != "localhost" && len(token2) != 4 {
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return true
+	}
+	token2 := strings.Split(host, ".")
+	if host != "localhost" && len(token2) != 4 {
 		return false
 	}
 	return true